@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"filekit/internal/generator"
 )
@@ -11,17 +12,102 @@ import (
 // ExecuteCreateRandFiles handles the create-rand-files command
 func ExecuteCreateRandFiles(args []string) {
 	fs := flag.NewFlagSet("create-rand-files", flag.ExitOnError)
-	depth := fs.Int("depth", 1, "Directory depth for file creation")
-	count := fs.Int("count", 5, "Number of files to create")
+	depth := fs.Int("depth", 1, "Directory depth for file creation (same as -min-depth and -max-depth)")
+	minDepth := fs.Int("min-depth", 0, "Depth every branch of the tree reaches (defaults to -depth)")
+	maxDepth := fs.Int("max-depth", 0, "Depth the deepest branch reaches (defaults to -depth)")
+	fanout := fs.Int("fanout", 1, "Subdirectories per directory")
+	dirNames := fs.String("dir-names", "level", "Directory names: level, random, or a comma separated list used for each directory's subdirectories")
+	spread := fs.Bool("spread", false, "Put files in every directory, not only those without subdirectories")
+	count := fs.Int("count", 5, "Total number of files to create, split across the tree")
+	size := fs.String("size", "", "File size, e.g. 512, 4K, 1.5MiB or 2GB (the median with -size-dist=lognormal)")
+	minSize := fs.String("min-size", "", "Smallest file size")
+	maxSize := fs.String("max-size", "", "Largest file size")
+	sizeDist := fs.String("size-dist", "", "Size distribution: fixed, uniform or lognormal (defaults to fixed with -size, uniform with -max-size)")
+	totalSize := fs.String("total-size", "", "Create files until this many bytes are written; -count then only limits the number if given")
+	content := fs.String("content", "text", "File content: text, random (incompressible), compressible or sparse")
+	types := fs.String("types", "", "Mix of file types by weight, e.g. png:30,txt:50,zip:20 (txt, png, jpeg, gif, zip, tar.gz, json, csv, pdf, mp3)")
+	names := fs.String("names", "simple", "File names: simple (adj_noun_123) or edge (Unicode, emoji, spaces, leading dots and dashes, 255-byte and glob names, a very deep branch)")
+	dupRatio := fs.Float64("dup-ratio", 0, "Fraction of files that copy an earlier file under another name in another directory")
+	hardlinkRatio := fs.Float64("hardlink-ratio", 0, "Fraction of files that are hard links to an earlier file")
+	sameSizeRatio := fs.Float64("same-size-ratio", 0, "Fraction of files with the size of an earlier file but different content")
+	lastByteRatio := fs.Float64("last-byte-ratio", 0, "Fraction of files that copy an earlier file with a different last byte")
+	jobs := fs.Int("jobs", 1, "Number of files to create at once; the tree is the same for any number")
+	groups := fs.String("groups", "", "Write the ground truth about duplicates to this JSON file")
+	seed := fs.Int64("seed", 0, "Seed for names, contents and structure; the same seed builds the same tree (0 = random)")
+	manifest := fs.String("manifest", "", "Write every created file with its size and SHA-256 to this JSON file")
+	spec := fs.String("spec", "", "Build the exact tree described by this .json or .yaml spec instead")
 
 	fs.Parse(args)
 
+	if *spec != "" {
+		createFromSpec(fs, *spec, *seed, *manifest)
+		return
+	}
+
 	if *depth < 1 {
 		fmt.Println("Error: depth must be at least 1")
 		os.Exit(1)
 	}
 
-	if *count < 1 {
+	if *jobs < 1 {
+		fmt.Println("Error: jobs must be at least 1")
+		os.Exit(1)
+	}
+
+	if *minDepth == 0 {
+		*minDepth = *depth
+	}
+	if *maxDepth == 0 {
+		*maxDepth = max(*depth, *minDepth)
+	}
+
+	sizes := make(map[string]int64)
+	for name, value := range map[string]string{"size": *size, "min-size": *minSize, "max-size": *maxSize, "total-size": *totalSize} {
+		if value == "" {
+			continue
+		}
+		n, err := generator.ParseSize(value)
+		if err != nil {
+			fmt.Printf("Error: -%s: %v\n", name, err)
+			os.Exit(1)
+		}
+		sizes[name] = n
+	}
+
+	dist, err := generator.ParseDistribution(*sizeDist)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	mode, err := generator.ParseContentMode(*content)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	nameProfile, err := generator.ParseNameProfile(*names)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var typeWeights []generator.TypeWeight
+	if *types != "" {
+		typeWeights, err = generator.ParseTypes(*types)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// With a total size, the count is a limit only when given explicitly
+	countSet := false
+	fs.Visit(func(f *flag.Flag) {
+		countSet = countSet || f.Name == "count"
+	})
+	if sizes["total-size"] > 0 && !countSet {
+		*count = 0
+	} else if *count < 1 {
 		fmt.Println("Error: count must be at least 1")
 		os.Exit(1)
 	}
@@ -32,11 +118,102 @@ func ExecuteCreateRandFiles(args []string) {
 		dir = fs.Arg(0)
 	}
 
-	err := generator.CreateRandomFiles(dir, *depth, *count)
+	opts := generator.Options{
+		MinDepth:      *minDepth,
+		MaxDepth:      *maxDepth,
+		Fanout:        *fanout,
+		Spread:        *spread,
+		Count:         *count,
+		Size:          sizes["size"],
+		MinSize:       sizes["min-size"],
+		MaxSize:       sizes["max-size"],
+		SizeDist:      dist,
+		TotalSize:     sizes["total-size"],
+		Content:       mode,
+		Types:         typeWeights,
+		Names:         nameProfile,
+		Jobs:          *jobs,
+		DupRatio:      *dupRatio,
+		HardlinkRatio: *hardlinkRatio,
+		SameSizeRatio: *sameSizeRatio,
+		LastByteRatio: *lastByteRatio,
+		Groups:        *groups,
+		Seed:          *seed,
+		Manifest:      *manifest,
+	}
+	if *dirNames == "level" || *dirNames == "random" {
+		opts.DirNames = *dirNames
+	} else {
+		opts.DirNameList = strings.Split(*dirNames, ",")
+	}
+
+	result, err := generator.CreateRandomFiles(dir, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		if result != nil {
+			fmt.Printf("%d files were created before the error\n", len(result.Files))
+		}
+		os.Exit(1)
+	}
+
+	if *seed == 0 {
+		fmt.Printf("Seed: %d (pass -seed=%d to build the same tree again)\n", result.Seed, result.Seed)
+	}
+	if *manifest != "" {
+		fmt.Printf("Manifest written to %s\n", *manifest)
+	}
+	if *groups != "" {
+		fmt.Printf("Groups written to %s\n", *groups)
+	}
+
+	depths := fmt.Sprintf("depth %d", *minDepth)
+	if *maxDepth > *minDepth {
+		depths = fmt.Sprintf("depths %d-%d", *minDepth, *maxDepth)
+	}
+	var total int64
+	for _, file := range result.Files {
+		total += file.Size
+	}
+	fmt.Printf("Successfully created %d random files (%s) in %d directories at %s in directory %s\n", len(result.Files), generator.FormatSize(total), len(result.Dirs)+1, depths, dir)
+}
+
+// createFromSpec builds the tree of a spec file; only -seed and -manifest
+// may be combined with -spec
+func createFromSpec(fs *flag.FlagSet, specPath string, seed int64, manifest string) {
+	var conflicting []string
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "spec" && f.Name != "seed" && f.Name != "manifest" {
+			conflicting = append(conflicting, "-"+f.Name)
+		}
+	})
+	if len(conflicting) > 0 {
+		fmt.Printf("Error: -spec cannot be combined with %s; set them in the spec\n", strings.Join(conflicting, ", "))
+		os.Exit(1)
+	}
+
+	spec, err := generator.LoadSpec(specPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to work in (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	result, err := generator.BuildSpec(dir, spec, seed, manifest)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Successfully created %d random files at depth %d in directory %s\n", *count, *depth, dir)
+	if seed == 0 && spec.Seed == 0 {
+		fmt.Printf("Seed: %d (pass -seed=%d to build the same tree again)\n", result.Seed, result.Seed)
+	}
+	if manifest != "" {
+		fmt.Printf("Manifest written to %s\n", manifest)
+	}
+	fmt.Printf("Successfully built %s: %d files and links in %d directories in directory %s\n", specPath, len(result.Files), len(result.Dirs), dir)
 }
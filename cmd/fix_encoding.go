@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteFixEncoding handles the fix-encoding command
+func ExecuteFixEncoding(args []string) {
+	fs := flag.NewFlagSet("fix-encoding", flag.ExitOnError)
+	from := fs.String("from", strings.Join(rename.DefaultCharsets, ","), "Charset of the broken names, or a comma separated list to guess from in order of preference")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	charsets, err := rename.ParseCharsets(*from)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := rename.EncodingOptions{
+		Charsets:    charsets,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.FixEncoding(absDir, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	run.report(count, scope.noun())
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteRenameCase handles the rename-case command
+func ExecuteRenameCase(args []string) {
+	fs := flag.NewFlagSet("rename-case", flag.ExitOnError)
+	mode := fs.String("mode", "", "Conversion to apply: lower, upper, title, snake, kebab, camel, nfc or nfd")
+	part := fs.String("part", "stem", "Part of the name to convert: stem, ext or full")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	if *mode == "" {
+		fmt.Println("Error: -mode flag is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	caseMode, err := rename.ParseCaseMode(*mode)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	namePart, err := rename.ParseNamePart(*part)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := rename.CaseOptions{
+		Part:        namePart,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.ChangeCase(absDir, caseMode, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	run.report(count, scope.noun())
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteRenameDates handles the rename-dates command
+func ExecuteRenameDates(args []string) {
+	fs := flag.NewFlagSet("rename-dates", flag.ExitOnError)
+	order := fs.String("order", "", "Field order for ambiguous numeric dates such as 01-02-2023: dmy, mdy or ymd")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	dateOrder, err := rename.ParseDateOrder(*order)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := rename.DateOptions{
+		Order:       dateOrder,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.NormalizeDates(absDir, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	run.report(count, scope.noun())
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteRenameEdit handles the rename-edit command
+func ExecuteRenameEdit(args []string) {
+	fs := flag.NewFlagSet("rename-edit", flag.ExitOnError)
+	editor := fs.String("editor", "", "Editor command (optional, defaults to $VISUAL, then $EDITOR, then vi)")
+	allowDelete := fs.Bool("delete", false, "Delete entries whose line was removed")
+	yes := fs.Bool("yes", false, "Apply the changes without asking for confirmation")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !*yes {
+		execOpts.Confirm = confirmPlan
+	}
+
+	opts := rename.EditOptions{
+		Editor:      *editor,
+		AllowDelete: *allowDelete,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.EditNames(absDir, opts)
+	if err == rename.ErrCancelled {
+		fmt.Println("Operation cancelled")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *run.dryRun {
+		run.report(count, scope.noun())
+		return
+	}
+
+	fmt.Printf("Successfully applied %d change(s)\n", count)
+}
+
+// confirmPlan asks whether the printed plan should be applied
+func confirmPlan(plan *rename.Plan) bool {
+	fmt.Printf("\nApply these %d change(s)? (y/N): ", len(plan.Pending()))
+	reader := bufio.NewReader(os.Stdin)
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"filekit/internal/rename"
+)
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// scopeFlags holds the flags the rename commands use to select entries
+type scopeFlags struct {
+	dirs       *bool
+	dirsOnly   *bool
+	maxDepth   *int
+	include    stringList
+	exclude    stringList
+	skipHidden *bool
+}
+
+// addScopeFlags registers the entry selection flags on fs
+func addScopeFlags(fs *flag.FlagSet) *scopeFlags {
+	f := &scopeFlags{}
+	f.dirs = fs.Bool("dirs", false, "Rename directories as well as files")
+	f.dirsOnly = fs.Bool("dirs-only", false, "Rename directories only, leaving files alone")
+	f.maxDepth = fs.Int("max-depth", 0, "Maximum depth to descend (1 = only the given directory, 0 = unlimited)")
+	fs.Var(&f.include, "include", "Only rename entries matching this glob (repeatable)")
+	fs.Var(&f.exclude, "exclude", "Skip entries matching this glob; excluded directories are not descended into (repeatable)")
+	f.skipHidden = fs.Bool("skip-hidden", false, "Skip hidden files and directories (names starting with '.')")
+	return f
+}
+
+// scope converts the parsed flags into a rename.Scope
+func (f *scopeFlags) scope() rename.Scope {
+	return rename.Scope{
+		Dirs:       *f.dirs,
+		DirsOnly:   *f.dirsOnly,
+		MaxDepth:   *f.maxDepth,
+		Include:    f.include,
+		Exclude:    f.exclude,
+		SkipHidden: *f.skipHidden,
+	}
+}
+
+// noun describes what the scope renames, for summary messages
+func (f *scopeFlags) noun() string {
+	if *f.dirsOnly {
+		return "directories"
+	}
+	if *f.dirs {
+		return "files and directories"
+	}
+	return "files"
+}
+
+// execFlags holds the flags the rename commands use to carry out a plan
+type execFlags struct {
+	dryRun     *bool
+	onConflict *string
+	sidecars   *bool
+	sidecarExt *string
+	updateRefs *bool
+	refExt     *string
+}
+
+// addExecFlags registers the dry-run and conflict policy flags on fs
+func addExecFlags(fs *flag.FlagSet) *execFlags {
+	f := &execFlags{}
+	f.dryRun = fs.Bool("dry-run", false, "Show the planned renames without changing anything")
+	f.onConflict = fs.String("on-conflict", "abort", "What to do when a new name is already taken: skip, abort, suffix or overwrite")
+	f.sidecars = fs.Bool("sidecars", false, "Rename sidecar files (movie.srt, movie.en.srt, movie-poster.jpg) together with their primary file")
+	f.sidecarExt = fs.String("sidecar-ext", strings.Join(rename.DefaultSidecarExts, ","), "Comma separated extensions treated as sidecars with -sidecars")
+	f.updateRefs = fs.Bool("update-refs", false, "Rewrite references to renamed files in playlists, cue sheets, Markdown and HTML files under the directory")
+	f.refExt = fs.String("ref-ext", strings.Join(rename.DefaultRefExts, ","), "Comma separated extensions of the files scanned with -update-refs")
+	return f
+}
+
+// options converts the parsed flags into rename.ExecOptions
+func (f *execFlags) options() (rename.ExecOptions, error) {
+	policy, err := rename.ParseConflictPolicy(*f.onConflict)
+	if err != nil {
+		return rename.ExecOptions{}, err
+	}
+	opts := rename.ExecOptions{
+		DryRun:     *f.dryRun,
+		OnConflict: policy,
+	}
+	if *f.sidecars {
+		opts.Sidecars, err = rename.ParseSidecarExts(*f.sidecarExt)
+		if err != nil {
+			return rename.ExecOptions{}, err
+		}
+	}
+	if *f.updateRefs {
+		opts.RefExts, err = rename.ParseRefExts(*f.refExt)
+		if err != nil {
+			return rename.ExecOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
+// report prints the summary line of a rename command
+func (f *execFlags) report(count int, noun string) {
+	if *f.dryRun {
+		fmt.Printf("Dry run: %d %s would be renamed, nothing was changed\n", count, noun)
+		return
+	}
+	fmt.Printf("Successfully renamed %d %s\n", count, noun)
+}
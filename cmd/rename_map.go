@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteRenameMap handles the rename-map command
+func ExecuteRenameMap(args []string) {
+	fs := flag.NewFlagSet("rename-map", flag.ExitOnError)
+	mapFile := fs.String("map", "", "Mapping file with old and new names (CSV, TSV or JSON)")
+	format := fs.String("format", "", "Mapping file format: csv, tsv or json (optional, defaults to the file extension)")
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	if *mapFile == "" {
+		fmt.Println("Error: -map flag is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	rows, err := rename.ReadMapping(*mapFile, *format)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	plan, err := rename.PlanMapping(absDir, rows)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	_, err = rename.Execute(plan, execOpts)
+
+	if *run.dryRun {
+		fmt.Printf("Dry run: %d of %d rows would be applied, nothing was changed\n", countRows(plan, rename.StatusPending), len(rows))
+	} else {
+		fmt.Println()
+		plan.Print()
+		fmt.Printf("Rows: %d applied, %d skipped, %d failed\n",
+			countRows(plan, rename.StatusApplied), countRows(plan, rename.StatusSkipped), countRows(plan, rename.StatusFailed))
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// countRows counts the mapping rows in the given state; sidecar files that
+// followed a row are not rows of their own
+func countRows(plan *rename.Plan, status rename.Status) int {
+	count := 0
+	for _, r := range plan.Renames {
+		if r.Status == status && r.SidecarOf == nil {
+			count++
+		}
+	}
+	return count
+}
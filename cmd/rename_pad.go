@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteRenamePad handles the rename-pad command
+func ExecuteRenamePad(args []string) {
+	fs := flag.NewFlagSet("rename-pad", flag.ExitOnError)
+	width := fs.Int("width", 0, "Pad numbers to this many digits (0 = the widest number in each directory)")
+	numbers := fs.String("numbers", "all", "Which numbers to change: first, last or all")
+	strip := fs.Bool("strip", false, "Remove zero padding instead of adding it")
+	part := fs.String("part", "stem", "Part of the name to look for numbers in: stem, ext or full")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	numberSelect, err := rename.ParseNumberSelect(*numbers)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	namePart, err := rename.ParseNamePart(*part)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := rename.PadOptions{
+		Width:       *width,
+		Numbers:     numberSelect,
+		Strip:       *strip,
+		Part:        namePart,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.PadNumbers(absDir, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	run.report(count, scope.noun())
+}
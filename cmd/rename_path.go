@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteRenamePath handles the rename-path command
+func ExecuteRenamePath(args []string) {
+	fs := flag.NewFlagSet("rename-path", flag.ExitOnError)
+	target := fs.String("target", "", "Target string to replace in the path relative to the directory")
+	replaceWith := fs.String("replaceWith", "", "String to replace target with; '/' moves files into other directories")
+	regex := fs.Bool("regex", false, "Treat target as a regular expression; replaceWith may use $1 or ${name} expansions")
+	ignoreCase := fs.Bool("ignore-case", false, "Match target case-insensitively")
+	removeEmpty := fs.Bool("remove-empty-dirs", false, "Remove directories left empty after the files are moved")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	if *target == "" {
+		fmt.Println("Error: -target flag is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := rename.PathOptions{
+		Regex:           *regex,
+		IgnoreCase:      *ignoreCase,
+		RemoveEmptyDirs: *removeEmpty,
+		Scope:           scope.scope(),
+		ExecOptions:     execOpts,
+	}
+
+	count, err := rename.RewritePaths(absDir, *target, *replaceWith, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	run.report(count, "files")
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteRenamePipeline handles the rename-pipeline command
+func ExecuteRenamePipeline(args []string) {
+	fs := flag.NewFlagSet("rename-pipeline", flag.ExitOnError)
+	var ops stringList
+	fs.Var(&ops, "op", "Pipeline step such as prefix:2024_, remove:0:4 or case:lower (repeatable, applied in order)")
+	recipeFile := fs.String("recipe", "", "JSON or YAML recipe file with the steps; -op steps run after it")
+	part := fs.String("part", "", "Part of the name the steps apply to: stem, ext or full (defaults to the recipe's part, then stem)")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	var steps []rename.Step
+	partName := "stem"
+
+	if *recipeFile != "" {
+		recipe, err := rename.LoadRecipe(*recipeFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		steps = append(steps, recipe.Steps...)
+		if recipe.Part != "" {
+			partName = recipe.Part
+		}
+	}
+
+	for _, op := range ops {
+		step, err := rename.ParseStep(op)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		steps = append(steps, step)
+	}
+
+	if len(steps) == 0 {
+		fmt.Println("Error: -op or -recipe is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *part != "" {
+		partName = *part
+	}
+	namePart, err := rename.ParseNamePart(partName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := rename.PipelineOptions{
+		Part:        namePart,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.RunPipeline(absDir, steps, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	run.report(count, scope.noun())
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteRenameTemplate handles the rename-template command
+func ExecuteRenameTemplate(args []string) {
+	fs := flag.NewFlagSet("rename-template", flag.ExitOnError)
+	template := fs.String("template", "", "Name template, e.g. \"Trip_2024_{n:03}{ext}\"")
+	sortOrder := fs.String("sort", "name", "Numbering order: name, natural, mtime or size")
+	reverse := fs.Bool("reverse", false, "Number in descending order")
+	start := fs.Int("start", 1, "First sequence number")
+	perDir := fs.Bool("per-dir", false, "Restart numbering in every directory")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	if *template == "" {
+		fmt.Println("Error: -template flag is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	order, err := rename.ParseSortOrder(*sortOrder)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := rename.TemplateOptions{
+		Sort:        order,
+		Reverse:     *reverse,
+		Start:       *start,
+		PerDir:      *perDir,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.RenameTemplate(absDir, *template, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	run.report(count, scope.noun())
+}
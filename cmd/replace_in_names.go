@@ -14,6 +14,11 @@ func ExecuteReplaceInNames(args []string) {
 	fs := flag.NewFlagSet("rename-replace", flag.ExitOnError)
 	target := fs.String("target", "", "Target string to replace in filenames")
 	replaceWith := fs.String("replaceWith", "", "String to replace target with (optional, defaults to empty string to remove target)")
+	regex := fs.Bool("regex", false, "Treat target as a regular expression; replaceWith may use $1 or ${name} expansions")
+	ignoreCase := fs.Bool("ignore-case", false, "Match target case-insensitively")
+	part := fs.String("part", "full", "Part of the name to replace in: stem, ext or full")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
 
 	fs.Parse(args)
 
@@ -23,6 +28,18 @@ func ExecuteReplaceInNames(args []string) {
 		os.Exit(1)
 	}
 
+	namePart, err := rename.ParseNamePart(*part)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Get the directory to process (default to current directory)
 	dir := "."
 	if fs.NArg() > 0 {
@@ -36,11 +53,19 @@ func ExecuteReplaceInNames(args []string) {
 		os.Exit(1)
 	}
 
-	count, err := rename.ReplaceInFilenames(absDir, *target, *replaceWith)
+	opts := rename.Options{
+		Regex:       *regex,
+		IgnoreCase:  *ignoreCase,
+		Part:        namePart,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.ReplaceInFilenames(absDir, *target, *replaceWith, opts)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Successfully renamed %d files\n", count)
+	run.report(count, scope.noun())
 }
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filekit/internal/rename"
+)
+
+// ExecuteSanitizeNames handles the sanitize-names command
+func ExecuteSanitizeNames(args []string) {
+	fs := flag.NewFlagSet("sanitize-names", flag.ExitOnError)
+	replacement := fs.String("replacement", "_", "Text put in place of illegal and control characters (may be empty)")
+	ascii := fs.Bool("ascii", false, "Transliterate names to ASCII, e.g. Café to Cafe")
+	maxBytes := fs.Int("max-bytes", rename.DefaultMaxNameBytes, "Longest allowed name in bytes; longer names are shortened, keeping the extension")
+	scope := addScopeFlags(fs)
+	run := addExecFlags(fs)
+
+	fs.Parse(args)
+
+	execOpts, err := run.options()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the directory to process (default to current directory)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Convert to absolute path
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("Error getting absolute path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := rename.SanitizeOptions{
+		Replacement: *replacement,
+		ASCII:       *ascii,
+		MaxBytes:    *maxBytes,
+		Scope:       scope.scope(),
+		ExecOptions: execOpts,
+	}
+
+	count, err := rename.SanitizeNames(absDir, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	run.report(count, scope.noun())
+}
@@ -0,0 +1,232 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Distribution is how file sizes are drawn
+type Distribution string
+
+const (
+	// DistFixed gives every file Size bytes
+	DistFixed Distribution = "fixed"
+	// DistUniform draws sizes evenly between MinSize and MaxSize
+	DistUniform Distribution = "uniform"
+	// DistLogNormal draws many small files and a few large ones, like real
+	// trees, around a median of Size (or between MinSize and MaxSize)
+	DistLogNormal Distribution = "lognormal"
+)
+
+// ParseDistribution converts a flag value into a Distribution
+func ParseDistribution(s string) (Distribution, error) {
+	switch d := Distribution(strings.ToLower(s)); d {
+	case "", DistFixed, DistUniform, DistLogNormal:
+		return d, nil
+	case "log-normal":
+		return DistLogNormal, nil
+	}
+	return "", fmt.Errorf("invalid size distribution '%s' (expected fixed, uniform or lognormal)", s)
+}
+
+// ContentMode is what generated files contain
+type ContentMode string
+
+const (
+	// ContentText is lorem ipsum sentences
+	ContentText ContentMode = "text"
+	// ContentRandom is random bytes, which do not compress
+	ContentRandom ContentMode = "random"
+	// ContentCompressible is a short random line repeated, which compresses
+	// to almost nothing
+	ContentCompressible ContentMode = "compressible"
+	// ContentSparse is all zeros, written as a hole where the filesystem
+	// supports it
+	ContentSparse ContentMode = "sparse"
+)
+
+// ParseContentMode converts a flag value into a ContentMode
+func ParseContentMode(s string) (ContentMode, error) {
+	switch m := ContentMode(strings.ToLower(s)); m {
+	case "", ContentText, ContentRandom, ContentCompressible, ContentSparse:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid content mode '%s' (expected text, random, compressible or sparse)", s)
+}
+
+// sizeUnits are the suffixes ParseSize accepts. K, M, G and the IEC forms
+// are powers of 1024; KB, MB and GB are powers of 1000.
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kib": 1 << 10, "kb": 1e3,
+	"m": 1 << 20, "mib": 1 << 20, "mb": 1e6,
+	"g": 1 << 30, "gib": 1 << 30, "gb": 1e9,
+	"t": 1 << 40, "tib": 1 << 40, "tb": 1e12,
+}
+
+// ParseSize parses a byte count such as "512", "4K", "1.5MiB" or "2GB"
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	number, unit := s, ""
+	if end >= 0 {
+		number, unit = s[:end], strings.ToLower(strings.TrimSpace(s[end:]))
+	}
+
+	multiplier, known := sizeUnits[unit]
+	value, err := strconv.ParseFloat(number, 64)
+	if !known || err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size '%s' (expected a number of bytes such as 512, 4K, 1.5MiB or 2GB)", s)
+	}
+	return int64(value * multiplier), nil
+}
+
+// FormatSize formats a byte count with a binary unit, e.g. "1.5 MiB"
+func FormatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	for _, unit := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		value /= 1024
+		if value < 1024 || unit == "TiB" {
+			return fmt.Sprintf("%.1f %s", value, unit)
+		}
+	}
+	return ""
+}
+
+// sized reports whether the options ask for file sizes; without them files
+// get a few sentences of text as before
+func (o Options) sized() bool {
+	return o.SizeDist != "" || o.Size > 0 || o.MaxSize > 0
+}
+
+// distribution returns the configured distribution, or the one implied by
+// the size flags given
+func (o Options) distribution() Distribution {
+	switch {
+	case o.SizeDist != "":
+		return o.SizeDist
+	case o.Size == 0 && o.MaxSize > 0:
+		return DistUniform
+	}
+	return DistFixed
+}
+
+// validateSizes checks the size and content options
+func (o Options) validateSizes() error {
+	if o.Size < 0 || o.MinSize < 0 || o.MaxSize < 0 || o.TotalSize < 0 {
+		return fmt.Errorf("sizes cannot be negative")
+	}
+	if o.MaxSize > 0 && o.MinSize > o.MaxSize {
+		return fmt.Errorf("min-size %s is larger than max-size %s", FormatSize(o.MinSize), FormatSize(o.MaxSize))
+	}
+	if o.Size > 0 && (o.Size < o.MinSize || o.MaxSize > 0 && o.Size > o.MaxSize) {
+		return fmt.Errorf("size %s is outside min-size and max-size", FormatSize(o.Size))
+	}
+	if o.Count == 0 && o.TotalSize == 0 {
+		return fmt.Errorf("either a file count or a total size is needed")
+	}
+
+	switch o.distribution() {
+	case DistFixed:
+		if o.sized() && o.Size == 0 && o.TotalSize > 0 {
+			return fmt.Errorf("a fixed size of 0 bytes can never fill a total size")
+		}
+	case DistUniform:
+		if o.MaxSize == 0 {
+			return fmt.Errorf("the uniform distribution needs max-size")
+		}
+	case DistLogNormal:
+		if o.Size == 0 && (o.MinSize == 0 || o.MaxSize == 0) {
+			return fmt.Errorf("the lognormal distribution needs size (the median) or both min-size and max-size")
+		}
+	}
+	if o.Content != "" && o.Content != ContentText && !o.sized() {
+		return fmt.Errorf("%s content needs a size", o.Content)
+	}
+	return nil
+}
+
+// fileSize draws the size of the next file
+func (g *generator) fileSize(opts Options) int64 {
+	switch opts.distribution() {
+	case DistUniform:
+		return opts.MinSize + g.rng.Int63n(opts.MaxSize-opts.MinSize+1)
+
+	case DistLogNormal:
+		// Without a median, min and max sit two standard deviations out
+		median, sigma := float64(opts.Size), 1.0
+		if opts.MinSize > 0 && opts.MaxSize > 0 {
+			low, high := math.Log(float64(opts.MinSize)), math.Log(float64(opts.MaxSize))
+			if median == 0 {
+				median = math.Exp((low + high) / 2)
+			}
+			sigma = max((high-low)/4, 0.01)
+		}
+		size := int64(math.Exp(math.Log(median) + sigma*g.rng.NormFloat64()))
+		if opts.MaxSize > 0 {
+			size = min(size, opts.MaxSize)
+		}
+		return max(size, opts.MinSize)
+	}
+	return opts.Size
+}
+
+// content returns size bytes of content in the given mode
+func (g *generator) content(mode ContentMode, size int64) io.Reader {
+	switch mode {
+	case ContentRandom:
+		return io.LimitReader(g.rng, size)
+	case ContentCompressible:
+		line := g.randomWords() + " " + strings.Repeat("=", 8+g.rng.Intn(56)) + "\n"
+		return io.LimitReader(&repeatReader{pattern: []byte(line)}, size)
+	case ContentSparse:
+		return io.LimitReader(zeroReader{}, size)
+	}
+	return io.LimitReader(&textReader{g: g, buf: []byte(fmt.Sprintf("Generated with seed: %d\n\n", g.seed))}, size)
+}
+
+// textReader yields random lorem ipsum sentences without end
+type textReader struct {
+	g   *generator
+	buf []byte
+}
+
+func (r *textReader) Read(p []byte) (int, error) {
+	for len(r.buf) < len(p) {
+		r.buf = append(r.buf, sentences[r.g.rng.Intn(len(sentences))]...)
+		r.buf = append(r.buf, '\n')
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// repeatReader yields pattern over and over
+type repeatReader struct {
+	pattern []byte
+	offset  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		copied := copy(p[n:], r.pattern[r.offset:])
+		n += copied
+		r.offset = (r.offset + copied) % len(r.pattern)
+	}
+	return n, nil
+}
+
+// zeroReader yields zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
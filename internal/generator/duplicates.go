@@ -0,0 +1,291 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// variant is how a file is derived from an earlier one
+type variant int
+
+const (
+	// variantCopy has the same content under another name
+	variantCopy variant = iota
+	// variantHardlink is another name for the same file
+	variantHardlink
+	// variantSameSize has the same size but different content
+	variantSameSize
+	// variantLastByte is a copy with only the last byte changed
+	variantLastByte
+)
+
+func (v variant) String() string {
+	return [...]string{"copy", "hardlink", "same-size", "last-byte"}[v]
+}
+
+// Groups is the ground truth about duplicates in a generated tree, for
+// tests of comparison and dedup tools to check their results against
+type Groups struct {
+	Seed int64 `json:"seed"`
+	// Identical lists every set of files with the same content, whether
+	// copies, hard links or files that came out the same by chance
+	Identical []ContentGroup `json:"identical"`
+	// Hardlinks, SameSize and LastByte each start with the original file,
+	// followed by the files derived from it
+	Hardlinks [][]string `json:"hardlinks"`
+	SameSize  [][]string `json:"same_size"`
+	LastByte  [][]string `json:"last_byte"`
+}
+
+// ContentGroup is a set of files with identical content
+type ContentGroup struct {
+	SHA256 string   `json:"sha256"`
+	Size   int64    `json:"size"`
+	Paths  []string `json:"paths"`
+}
+
+// groupSet collects groups of an original and its variants in the order
+// the originals were first used
+type groupSet struct {
+	groups [][]string
+	index  map[string]int
+}
+
+func (s *groupSet) add(original, path string) {
+	if s.index == nil {
+		s.index = make(map[string]int)
+	}
+	i, exists := s.index[original]
+	if !exists {
+		i = len(s.groups)
+		s.index[original] = i
+		s.groups = append(s.groups, []string{original})
+	}
+	s.groups[i] = append(s.groups[i], path)
+}
+
+// list returns the groups, never nil so that they encode as []
+func (s *groupSet) list() [][]string {
+	if s.groups == nil {
+		return [][]string{}
+	}
+	return s.groups
+}
+
+// createdFile is a file of the current run that later files may be derived
+// from; derived files are never used as originals themselves
+type createdFile struct {
+	path   string
+	dir    string
+	ext    string
+	size   int64
+	sparse bool
+	// sha256 is set once the file is written, before done is closed; it
+	// stays empty if writing failed
+	sha256 string
+	done   chan struct{}
+}
+
+// derives reports whether the options ask for derived files
+func (o Options) derives() bool {
+	return o.DupRatio > 0 || o.HardlinkRatio > 0 || o.SameSizeRatio > 0 || o.LastByteRatio > 0
+}
+
+// validateRatios checks the duplicate options
+func (o Options) validateRatios() error {
+	ratios := []float64{o.DupRatio, o.HardlinkRatio, o.SameSizeRatio, o.LastByteRatio}
+	sum := 0.0
+	for _, r := range ratios {
+		if r < 0 || r > 1 {
+			return fmt.Errorf("ratios must be between 0 and 1")
+		}
+		sum += r
+	}
+	if sum > 1 {
+		return fmt.Errorf("the duplicate ratios add up to %.2f, more than 1", sum)
+	}
+	if o.Count == 0 && o.HardlinkRatio == 1 {
+		return fmt.Errorf("hard links add no bytes, so a hardlink ratio of 1 never fills a total size")
+	}
+	return nil
+}
+
+// pickVariant decides whether the next file is derived from an earlier
+// one, and from which. It draws nothing unless the options derive files.
+func (g *generator) pickVariant(opts Options, created []*createdFile) (variant, *createdFile) {
+	if !opts.derives() || len(created) == 0 {
+		return 0, nil
+	}
+
+	roll := g.rng.Float64()
+	source := created[g.rng.Intn(len(created))]
+	for _, v := range []struct {
+		kind  variant
+		ratio float64
+	}{
+		{variantCopy, opts.DupRatio},
+		{variantHardlink, opts.HardlinkRatio},
+		{variantSameSize, opts.SameSizeRatio},
+		{variantLastByte, opts.LastByteRatio},
+	} {
+		if roll < v.ratio {
+			return v.kind, source
+		}
+		roll -= v.ratio
+	}
+	return 0, nil
+}
+
+// canDerive reports whether source can have a variant of kind that fits in
+// remaining bytes of the total size
+func (o Options) canDerive(kind variant, source *createdFile, remaining int64) bool {
+	if kind == variantHardlink {
+		return true
+	}
+	if o.TotalSize > 0 && source.size > remaining {
+		return false
+	}
+	return kind == variantCopy || source.size > 0
+}
+
+// sameSizeData draws new content of the size of source
+func (g *generator) sameSizeData(source *createdFile, opts Options) *fileData {
+	if opts.Content != ContentSparse {
+		return &fileData{r: g.content(opts.Content, source.size), size: source.size}
+	}
+	// A few random bytes at the end keep the file sparse; a first byte
+	// other than 0 tells it apart from an all zero original
+	tail := make([]byte, min(8, source.size))
+	g.rng.Read(tail)
+	tail[0] |= 1
+	return &fileData{size: source.size, sparse: true, tail: tail}
+}
+
+// variantData returns the content of a copy or last byte variant of
+// source, which must exist by now. The original, if opened to stream from,
+// is returned for the caller to close.
+func variantData(kind variant, source *createdFile) (*fileData, *os.File, error) {
+	data := &fileData{size: source.size, sparse: source.sparse}
+	if source.sparse {
+		if kind == variantLastByte {
+			data.tail = []byte{0xff}
+		}
+		return data, nil, nil
+	}
+
+	f, err := os.Open(source.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data.r = f
+	if kind == variantLastByte {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, source.size-1); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		data.r = io.MultiReader(io.LimitReader(f, source.size-1), bytes.NewReader([]byte{^last[0]}))
+	}
+	return data, f, nil
+}
+
+// flipFirstByte changes the first byte of the file at path, for new
+// content that came out the same as its original, and returns the new
+// SHA-256
+func flipFirstByte(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	content[0] ^= 0xff
+	_, sum, err := writeData(path, &fileData{r: bytes.NewReader(content)}, os.O_TRUNC)
+	return sum, err
+}
+
+// recordVariant adds a derived file to the ground truth groups; copies are
+// found by their content
+func (g *generator) recordVariant(kind variant, source *createdFile, path string) {
+	original, _ := filepath.Rel(g.baseDir, source.path)
+	rel, _ := filepath.Rel(g.baseDir, path)
+	switch kind {
+	case variantHardlink:
+		g.hardlinks.add(filepath.ToSlash(original), filepath.ToSlash(rel))
+	case variantSameSize:
+		g.sameSize.add(filepath.ToSlash(original), filepath.ToSlash(rel))
+	case variantLastByte:
+		g.lastByte.add(filepath.ToSlash(original), filepath.ToSlash(rel))
+	}
+}
+
+// link creates path as a hard link to target and records it in the
+// manifest with the content of its target
+func (g *generator) link(target, path string) error {
+	if err := os.Link(target, path); err != nil {
+		return fmt.Errorf("failed to create hardlink %s: %v", path, err)
+	}
+
+	rel, err := filepath.Rel(g.baseDir, path)
+	if err != nil {
+		return err
+	}
+	targetRel, err := filepath.Rel(g.baseDir, target)
+	if err != nil {
+		return err
+	}
+	linked := ManifestFile{Path: filepath.ToSlash(rel), Type: "hardlink", Target: filepath.ToSlash(targetRel)}
+	if i, exists := g.index[linked.Target]; exists {
+		linked.Size, linked.SHA256 = g.manifest.Files[i].Size, g.manifest.Files[i].SHA256
+	}
+	g.manifest.Files = append(g.manifest.Files, linked)
+	fmt.Printf("Created hardlink: %s -> %s\n", path, target)
+	return nil
+}
+
+// groups builds the ground truth of the run from the manifest and the
+// variants created
+func (g *generator) groups() *Groups {
+	groups := &Groups{
+		Seed:      g.seed,
+		Identical: []ContentGroup{},
+		Hardlinks: g.hardlinks.list(),
+		SameSize:  g.sameSize.list(),
+		LastByte:  g.lastByte.list(),
+	}
+
+	bySum := make(map[string]int)
+	var all []ContentGroup
+	for _, file := range g.manifest.Files {
+		if file.SHA256 == "" {
+			continue
+		}
+		i, exists := bySum[file.SHA256]
+		if !exists {
+			i = len(all)
+			bySum[file.SHA256] = i
+			all = append(all, ContentGroup{SHA256: file.SHA256, Size: file.Size})
+		}
+		all[i].Paths = append(all[i].Paths, file.Path)
+	}
+	for _, group := range all {
+		if len(group.Paths) > 1 {
+			groups.Identical = append(groups.Identical, group)
+		}
+	}
+	return groups
+}
+
+// WriteGroups writes groups to path as indented JSON
+func WriteGroups(path string, groups *Groups) error {
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode groups: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write groups: %v", err)
+	}
+	return nil
+}
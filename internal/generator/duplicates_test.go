@@ -0,0 +1,122 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateGroups(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"text", Options{Count: 80, DupRatio: 0.2, HardlinkRatio: 0.2, SameSizeRatio: 0.2, LastByteRatio: 0.2, Seed: 1}},
+		{"random content", Options{Count: 60, Size: 2 << 10, Content: ContentRandom, DupRatio: 0.25, SameSizeRatio: 0.25, LastByteRatio: 0.25, Seed: 2}},
+		{"sparse", Options{Count: 30, Size: 1 << 20, Content: ContentSparse, DupRatio: 0.3, SameSizeRatio: 0.3, LastByteRatio: 0.3, Seed: 3}},
+		{"typed", Options{Count: 40, Types: []TypeWeight{{TypePNG, 1}, {TypeZip, 1}}, DupRatio: 0.3, LastByteRatio: 0.3, Seed: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.MinDepth, opts.MaxDepth, opts.Fanout = 2, 2, 3
+			dir := t.TempDir()
+			m, err := CreateRandomFiles(dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Groups.Identical) == 0 {
+				t.Fatal("no duplicates were created")
+			}
+			checkGroups(t, dir, m)
+		})
+	}
+}
+
+// checkGroups compares the ground truth of a run with the files on disk
+func checkGroups(t *testing.T, dir string, m *Manifest) {
+	t.Helper()
+	read := func(rel string) []byte {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+
+	bySum := make(map[string]int)
+	for _, file := range m.Files {
+		bySum[file.SHA256]++
+	}
+	shared := 0
+	for _, n := range bySum {
+		if n > 1 {
+			shared++
+		}
+	}
+	if len(m.Groups.Identical) != shared {
+		t.Errorf("%d identical groups, but %d contents are shared", len(m.Groups.Identical), shared)
+	}
+	for _, group := range m.Groups.Identical {
+		if len(group.Paths) != bySum[group.SHA256] {
+			t.Errorf("identical group %s lists %d of %d files", group.SHA256, len(group.Paths), bySum[group.SHA256])
+		}
+		first := read(group.Paths[0])
+		for _, path := range group.Paths[1:] {
+			if !bytes.Equal(read(path), first) {
+				t.Errorf("%s differs from %s", path, group.Paths[0])
+			}
+		}
+	}
+
+	for _, group := range m.Groups.Hardlinks {
+		original, _ := os.Stat(filepath.Join(dir, filepath.FromSlash(group[0])))
+		for _, path := range group[1:] {
+			if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path))); err != nil || !os.SameFile(info, original) {
+				t.Errorf("%s is not a hard link to %s", path, group[0])
+			}
+		}
+	}
+	for _, group := range m.Groups.SameSize {
+		original := read(group[0])
+		for _, path := range group[1:] {
+			if content := read(path); len(content) != len(original) || bytes.Equal(content, original) {
+				t.Errorf("%s is not a same-size variant of %s", path, group[0])
+			}
+		}
+	}
+	for _, group := range m.Groups.LastByte {
+		original := read(group[0])
+		for _, path := range group[1:] {
+			content := read(path)
+			last := len(content) - 1
+			if len(content) != len(original) || last < 0 || !bytes.Equal(content[:last], original[:last]) || content[last] == original[last] {
+				t.Errorf("%s is not a last-byte variant of %s", path, group[0])
+			}
+		}
+	}
+}
+
+func TestValidateRatios(t *testing.T) {
+	tests := []struct {
+		opts    Options
+		wantErr string
+	}{
+		{Options{DupRatio: 0.5, HardlinkRatio: 0.5}, ""},
+		{Options{DupRatio: -0.1}, "between 0 and 1"},
+		{Options{LastByteRatio: 1.5}, "between 0 and 1"},
+		{Options{DupRatio: 0.6, SameSizeRatio: 0.6}, "add up to 1.20"},
+		{Options{HardlinkRatio: 1, TotalSize: 1 << 20}, "never fills a total size"},
+		{Options{HardlinkRatio: 1, Count: 5}, ""},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.validateRatios()
+		if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validateRatios(%+v) error = %v, want %q", tt.opts, err, tt.wantErr)
+		}
+	}
+}
@@ -1,111 +1,581 @@
 package generator
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
-// CreateRandomFiles creates random text files at specified depth with random names
-func CreateRandomFiles(baseDir string, depth, count int) error {
-	// Seed the random number generator
-	rand.Seed(time.Now().UnixNano())
+// MaxDirs limits how many directories a tree shape may produce
+const MaxDirs = 100000
+
+// Options controls CreateRandomFiles
+type Options struct {
+	// MinDepth and MaxDepth bound the depth of the tree; 1 is baseDir. Every
+	// branch reaches at least MinDepth and one branch reaches MaxDepth.
+	MinDepth int
+	MaxDepth int
+	// Fanout is the number of subdirectories per directory; below MinDepth
+	// every directory has Fanout of them, deeper ones 0 to Fanout
+	Fanout int
+	// DirNames names the directories: "level" (level_1, or level_1_2 with a
+	// fanout above 1) or "random"; DirNameList, when set, is used instead
+	DirNames    string
+	DirNameList []string
+	// Spread puts files in every directory of the tree instead of only the
+	// directories without subdirectories
+	Spread bool
+	// Count is the total number of files, split at random across the tree;
+	// 0 means as many as TotalSize takes
+	Count int
+	// Size, MinSize and MaxSize set the file sizes in bytes, drawn with
+	// SizeDist. With none of them set, files get a few sentences of text.
+	Size     int64
+	MinSize  int64
+	MaxSize  int64
+	SizeDist Distribution
+	// TotalSize, when set, stops creating files once this many bytes have
+	// been written, shortening the last file to fit exactly
+	TotalSize int64
+	// Content is what the text files contain; the zero value means text
+	Content ContentMode
+	// Types sets the mix of file types by weight; without types every file
+	// is text. Sizes and Content only apply to text files, the others are
+	// complete files of their natural size.
+	Types []TypeWeight
+	// DupRatio, HardlinkRatio, SameSizeRatio and LastByteRatio are the
+	// fractions of files derived from an earlier file of the run: a copy
+	// under another name in another directory, a hard link, a file of the
+	// same size with different content, and a copy whose last byte differs
+	DupRatio      float64
+	HardlinkRatio float64
+	SameSizeRatio float64
+	LastByteRatio float64
+	// Names is how files are named; the zero value means NamesSimple
+	Names NameProfile
+	// Jobs is the number of files created at once; the tree is the same
+	// for any number
+	Jobs int
+	// Groups, when set, is the path the ground truth about duplicates is
+	// written to as JSON
+	Groups string
+	// Seed makes names, contents and structure reproducible: the same seed
+	// and options always build the same tree. 0 picks a random seed, which
+	// is reported in the manifest.
+	Seed int64
+	// Manifest, when set, is the path the manifest is written to as JSON
+	Manifest string
+}
+
+// Manifest describes the directories and files a run created
+type Manifest struct {
+	Seed  int64          `json:"seed"`
+	Dirs  []string       `json:"dirs"`
+	Files []ManifestFile `json:"files"`
+	// Groups is set when the run derived files or was asked for groups
+	Groups *Groups `json:"groups,omitempty"`
+}
+
+// ManifestFile is one created file, with its path relative to the base
+// directory in slash form. Links have a Type and a Target; a hard link has
+// the size and hash of its target.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+// generator holds the state of one run
+type generator struct {
+	rng      *rand.Rand
+	seed     int64
+	baseDir  string
+	manifest *Manifest
+	// index maps a path to its manifest entry, so that a file written twice
+	// is listed once
+	index map[string]int
+	// hardlinks, sameSize and lastByte group the derived files by original
+	hardlinks groupSet
+	sameSize  groupSet
+	lastByte  groupSet
+	// edgeNames are the edge profile names drawn per directory, for names
+	// that differ only by case
+	edgeNames map[string][]string
+}
+
+// Validate checks that the options describe a tree that can be built
+func (o Options) Validate() error {
+	if o.MinDepth < 1 || o.MaxDepth < o.MinDepth {
+		return fmt.Errorf("invalid depth range %d-%d (need 1 <= min-depth <= max-depth)", o.MinDepth, o.MaxDepth)
+	}
+	if o.Fanout < 1 {
+		return fmt.Errorf("fanout must be at least 1")
+	}
+	if o.Count < 0 {
+		return fmt.Errorf("invalid file count %d", o.Count)
+	}
+	if err := o.validateSizes(); err != nil {
+		return err
+	}
+	if err := o.validateRatios(); err != nil {
+		return err
+	}
+	switch {
+	case len(o.DirNameList) > 0:
+		if len(o.DirNameList) < o.Fanout {
+			return fmt.Errorf("%d directory names given, but a fanout of %d needs at least that many", len(o.DirNameList), o.Fanout)
+		}
+		seen := make(map[string]bool)
+		for _, name := range o.DirNameList {
+			if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || seen[name] {
+				return fmt.Errorf("invalid or repeated directory name '%s'", name)
+			}
+			seen[name] = true
+		}
+	case o.DirNames != "" && o.DirNames != "level" && o.DirNames != "random":
+		return fmt.Errorf("invalid directory naming '%s' (expected level, random or a list of names)", o.DirNames)
+	}
+	if _, err := ParseNameProfile(string(o.Names)); err != nil {
+		return err
+	}
+
+	// Upper bound of a full tree: 1 + f + f^2 + ... + f^(max-1)
+	total, level := 1, 1
+	for d := 1; d < o.MaxDepth; d++ {
+		level *= o.Fanout
+		total += level
+		if total > MaxDirs {
+			return fmt.Errorf("a fanout of %d to depth %d could create more than %d directories", o.Fanout, o.MaxDepth, MaxDirs)
+		}
+	}
+	return nil
+}
+
+// CreateRandomFiles creates a tree of random text files with random names.
+// Existing files are never replaced: a name that is taken gets a number.
+// If creating a file fails, the manifest returned with the error lists the
+// files created before.
+func CreateRandomFiles(baseDir string, opts Options) (*Manifest, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	g, err := newGenerator(baseDir, opts.Seed)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.generate(g.baseDir, opts); err != nil {
+		return g.manifest, err
+	}
+
+	if opts.derives() || opts.Groups != "" {
+		g.manifest.Groups = g.groups()
+	}
+	if opts.Manifest != "" {
+		if err := WriteManifest(opts.Manifest, g.manifest); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Groups != "" {
+		if err := WriteGroups(opts.Groups, g.manifest.Groups); err != nil {
+			return nil, err
+		}
+	}
+
+	return g.manifest, nil
+}
+
+// newGenerator starts a run under baseDir; a seed of 0 picks a random one
+func newGenerator(baseDir string, seed int64) (*generator, error) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
 
 	// Convert to absolute path
 	absBaseDir, err := filepath.Abs(baseDir)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+		return nil, fmt.Errorf("failed to get absolute path: %v", err)
 	}
 
-	// Create directory structure if needed
-	if depth > 1 {
-		err := createDirectoryStructure(absBaseDir, depth)
-		if err != nil {
-			return fmt.Errorf("failed to create directory structure: %v", err)
+	return &generator{
+		rng:       rand.New(rand.NewSource(seed)),
+		seed:      seed,
+		baseDir:   absBaseDir,
+		manifest:  &Manifest{Seed: seed, Dirs: []string{}, Files: []ManifestFile{}},
+		index:     make(map[string]int),
+		edgeNames: make(map[string][]string),
+	}, nil
+}
+
+// generate creates a random tree under root as described by opts
+func (g *generator) generate(root string, opts Options) error {
+	dirs, err := g.buildTree(root, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create directory structure: %v", err)
+	}
+
+	// Files go in the leaves, or anywhere with Spread
+	var targets []string
+	for _, dir := range dirs {
+		if opts.Spread || dir.children == 0 {
+			targets = append(targets, dir.path)
+		}
+	}
+	deepest := ""
+	if opts.Names == NamesEdge {
+		if deepest, err = g.deepBranch(root); err != nil {
+			return fmt.Errorf("failed to create deep branch: %v", err)
 		}
 	}
 
-	// Create files at the specified depth
-	targetDir := getTargetDirectory(absBaseDir, depth)
+	p := g.startPool(opts)
+	var jobs []*job
+	var written int64
+	var created []*createdFile
+	for i := 0; (opts.Count == 0 || i < opts.Count) && !p.failed(); i++ {
+		remaining := opts.TotalSize - written
+		if opts.TotalSize > 0 && remaining <= 0 {
+			break
+		}
+
+		kind, source := g.pickVariant(opts, created)
 
-	for i := 0; i < count; i++ {
-		filename := generateRandomFilename() + ".txt"
-		filePath := filepath.Join(targetDir, filename)
+		// A copy goes to another directory than its original
+		candidates := targets
+		if source != nil && kind == variantCopy && len(targets) > 1 {
+			candidates = make([]string, 0, len(targets)-1)
+			for _, dir := range targets {
+				if dir != source.dir {
+					candidates = append(candidates, dir)
+				}
+			}
+		}
+		targetDir := candidates[0]
+		if len(candidates) > 1 {
+			targetDir = candidates[g.rng.Intn(len(candidates))]
+		}
+		// The first file of an edge tree goes to the end of the deep branch
+		if i == 0 && deepest != "" {
+			targetDir = deepest
+		}
 
-		content := generateRandomContent()
+		var name string
+		if opts.Names == NamesEdge {
+			name = g.edgeName(targetDir)
+		} else {
+			name = g.randomFilename()
+		}
 
-		err := os.WriteFile(filePath, []byte(content), 0644)
-		if err != nil {
-			return fmt.Errorf("failed to create file %s: %v", filePath, err)
+		j := &job{fg: g.fork()}
+		if source != nil && opts.canDerive(kind, source, remaining) {
+			j.kind, j.source = kind, source
+			if kind == variantSameSize {
+				j.data = j.fg.sameSizeData(source, opts)
+			}
+			if kind != variantHardlink {
+				written += source.size
+			}
+			j.path = p.reserve(targetDir, name, source.ext)
+			g.recordVariant(kind, source, j.path)
+		} else {
+			j.fileType = j.fg.pickType(opts.Types)
+			// The size is needed now to fill a total size, and the content
+			// to derive files from it; otherwise the worker draws it
+			if opts.TotalSize > 0 || opts.derives() {
+				var err error
+				if j.fileType, j.data, err = j.fg.fileContent(j.fileType, opts, remaining); err != nil {
+					p.wait()
+					g.collect(jobs)
+					return err
+				}
+				written += j.data.size
+			}
+			j.path = p.reserve(targetDir, name, typeExts[j.fileType])
+			if opts.derives() {
+				j.created = &createdFile{
+					path:   j.path,
+					dir:    targetDir,
+					ext:    typeExts[j.fileType],
+					size:   j.data.size,
+					sparse: j.data.sparse,
+					done:   make(chan struct{}),
+				}
+				created = append(created, j.created)
+			}
 		}
 
-		fmt.Printf("Created: %s\n", filePath)
+		jobs = append(jobs, j)
+		p.jobs <- j
 	}
 
-	return nil
+	err = p.wait()
+	g.collect(jobs)
+	return err
 }
 
-// createDirectoryStructure creates nested directories up to the specified depth
-func createDirectoryStructure(baseDir string, depth int) error {
-	path := baseDir
-	for i := 1; i < depth; i++ {
-		dirName := fmt.Sprintf("level_%d", i)
-		path = filepath.Join(path, dirName)
+// collect adds the files of finished jobs to the manifest in the order
+// they were drawn
+func (g *generator) collect(jobs []*job) {
+	for _, j := range jobs {
+		if j.result != nil {
+			g.index[j.result.Path] = len(g.manifest.Files)
+			g.manifest.Files = append(g.manifest.Files, *j.result)
+		}
+	}
+}
 
-		err := os.MkdirAll(path, 0755)
+// fileData is the content of a file about to be created
+type fileData struct {
+	r      io.Reader
+	size   int64
+	sparse bool
+	// tail is written over the last bytes of a sparse file
+	tail []byte
+}
+
+// fileContent draws the content of a file of type t. remaining is what is
+// left of opts.TotalSize; a typed file that does not fit becomes text, so
+// the returned type may differ from t.
+func (g *generator) fileContent(t FileType, opts Options, remaining int64) (FileType, *fileData, error) {
+	if t != TypeText {
+		data, err := g.typedContent(t)
 		if err != nil {
-			return err
+			return t, nil, fmt.Errorf("failed to generate %s file: %v", t, err)
+		}
+		// A typed file cannot be cut short, so the last one becomes text
+		if opts.TotalSize == 0 || int64(len(data)) <= remaining {
+			return t, &fileData{r: bytes.NewReader(data), size: int64(len(data))}, nil
 		}
 	}
-	return nil
+
+	if opts.sized() {
+		size := g.fileSize(opts)
+		if opts.TotalSize > 0 {
+			size = min(size, remaining)
+		}
+		return TypeText, &fileData{r: g.content(opts.Content, size), size: size, sparse: opts.Content == ContentSparse}, nil
+	}
+
+	text := g.randomContent()
+	if opts.TotalSize > 0 && int64(len(text)) > remaining {
+		text = text[:remaining]
+	}
+	return TypeText, &fileData{r: strings.NewReader(text), size: int64(len(text))}, nil
 }
 
-// getTargetDirectory returns the target directory at the specified depth
-func getTargetDirectory(baseDir string, depth int) string {
-	if depth == 1 {
-		return baseDir
+// create writes data to path, replacing any file there, and records it in
+// the manifest
+func (g *generator) create(path string, data *fileData) error {
+	size, sum, err := writeData(path, data, os.O_TRUNC)
+	if err != nil {
+		return err
 	}
+	return g.record(path, size, sum)
+}
 
-	path := baseDir
-	for i := 1; i < depth; i++ {
-		dirName := fmt.Sprintf("level_%d", i)
-		path = filepath.Join(path, dirName)
+// writeData writes data to path and returns its size and SHA-256. flag is
+// os.O_EXCL to refuse an existing file or os.O_TRUNC to replace it.
+func writeData(path string, data *fileData, flag int) (int64, []byte, error) {
+	if data.sparse {
+		return writeSparse(path, data.size, data.tail, flag)
 	}
-	return path
+	return writeFile(path, data.r, flag)
 }
 
-// generateRandomFilename generates a random filename
-func generateRandomFilename() string {
-	adjectives := []string{"quick", "lazy", "happy", "sad", "big", "small", "fast", "slow", "bright", "dark"}
-	nouns := []string{"cat", "dog", "bird", "fish", "tree", "rock", "star", "moon", "sun", "cloud"}
+// writeFile creates a file from content
+func writeFile(path string, content io.Reader, flag int) (int64, []byte, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create file %s: %v", path, err)
+	}
 
-	adj := adjectives[rand.Intn(len(adjectives))]
-	noun := nouns[rand.Intn(len(nouns))]
-	num := rand.Intn(1000)
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to write file %s: %v", path, err)
+	}
+	return size, hash.Sum(nil), nil
+}
 
-	return fmt.Sprintf("%s_%s_%d", adj, noun, num)
+// writeSparse creates a file of size zero bytes without writing them, so
+// that it takes no space on filesystems with sparse file support. tail, if
+// any, is written over the last bytes.
+func writeSparse(path string, size int64, tail []byte, flag int) (int64, []byte, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create file %s: %v", path, err)
+	}
+	err = f.Truncate(size)
+	if err == nil && len(tail) > 0 {
+		_, err = f.WriteAt(tail, size-int64(len(tail)))
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to write file %s: %v", path, err)
+	}
+
+	hash := sha256.New()
+	if _, err := io.CopyN(hash, zeroReader{}, size-int64(len(tail))); err != nil {
+		return 0, nil, err
+	}
+	hash.Write(tail)
+	return size, hash.Sum(nil), nil
 }
 
-// generateRandomContent generates random content for the file
-func generateRandomContent() string {
-	sentences := []string{
-		"This is a randomly generated file.",
-		"The quick brown fox jumps over the lazy dog.",
-		"Lorem ipsum dolor sit amet, consectetur adipiscing elit.",
-		"Sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.",
-		"Ut enim ad minim veniam, quis nostrud exercitation ullamco.",
-		"Duis aute irure dolor in reprehenderit in voluptate velit esse.",
-		"Excepteur sint occaecat cupidatat non proident, sunt in culpa.",
+// record adds a created file to the manifest
+func (g *generator) record(path string, size int64, sum []byte) error {
+	rel, err := filepath.Rel(g.baseDir, path)
+	if err != nil {
+		return err
+	}
+	entry := ManifestFile{
+		Path:   filepath.ToSlash(rel),
+		Size:   size,
+		SHA256: hex.EncodeToString(sum),
+	}
+
+	if i, exists := g.index[entry.Path]; exists {
+		g.manifest.Files[i] = entry
+		return nil
+	}
+	g.index[entry.Path] = len(g.manifest.Files)
+	g.manifest.Files = append(g.manifest.Files, entry)
+	return nil
+}
+
+// WriteManifest writes m to path as indented JSON
+func WriteManifest(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
 	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
+
+// dirNode is a directory of the generated tree
+type dirNode struct {
+	path     string
+	level    int
+	children int
+	// spine is the branch that always reaches MaxDepth
+	spine bool
+}
+
+// buildTree creates the directories of the tree under root breadth first
+// and returns them, root first
+func (g *generator) buildTree(root string, opts Options) ([]*dirNode, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	dirs := []*dirNode{{path: root, level: 1, spine: true}}
+	for i := 0; i < len(dirs); i++ {
+		parent := dirs[i]
+		if parent.level >= opts.MaxDepth {
+			continue
+		}
+
+		children := opts.Fanout
+		if parent.level >= opts.MinDepth {
+			children = g.rng.Intn(opts.Fanout + 1)
+			if parent.spine {
+				children = max(children, 1)
+			}
+		}
+
+		used := make(map[string]bool, children)
+		for c := 0; c < children; c++ {
+			name := g.dirName(opts, parent.level, c, used)
+			used[name] = true
+
+			path := filepath.Join(parent.path, name)
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return nil, err
+			}
+			rel, _ := filepath.Rel(g.baseDir, path)
+			g.manifest.Dirs = append(g.manifest.Dirs, filepath.ToSlash(rel))
+
+			dirs = append(dirs, &dirNode{path: path, level: parent.level + 1, spine: parent.spine && c == 0})
+			parent.children++
+		}
+	}
+	return dirs, nil
+}
+
+// dirName names the index-th subdirectory of a directory at parentLevel,
+// avoiding the names its siblings already have
+func (g *generator) dirName(opts Options, parentLevel, index int, used map[string]bool) string {
+	switch {
+	case len(opts.DirNameList) > 0:
+		return opts.DirNameList[index]
+	case opts.DirNames == "random":
+		for attempt := 0; attempt < 10; attempt++ {
+			if name := g.randomWords(); !used[name] {
+				return name
+			}
+		}
+		return fmt.Sprintf("%s_%d", g.randomWords(), index+1)
+	case opts.Fanout > 1:
+		return fmt.Sprintf("level_%d_%d", parentLevel, index+1)
+	}
+	return fmt.Sprintf("level_%d", parentLevel)
+}
+
+// randomWords returns a random adjective_noun pair
+func (g *generator) randomWords() string {
+	return fmt.Sprintf("%s_%s", adjectives[g.rng.Intn(len(adjectives))], nouns[g.rng.Intn(len(nouns))])
+}
+
+var sentences = []string{
+	"This is a randomly generated file.",
+	"The quick brown fox jumps over the lazy dog.",
+	"Lorem ipsum dolor sit amet, consectetur adipiscing elit.",
+	"Sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.",
+	"Ut enim ad minim veniam, quis nostrud exercitation ullamco.",
+	"Duis aute irure dolor in reprehenderit in voluptate velit esse.",
+	"Excepteur sint occaecat cupidatat non proident, sunt in culpa.",
+}
+
+var (
+	adjectives = []string{"quick", "lazy", "happy", "sad", "big", "small", "fast", "slow", "bright", "dark"}
+	nouns      = []string{"cat", "dog", "bird", "fish", "tree", "rock", "star", "moon", "sun", "cloud"}
+)
+
+// randomFilename generates a random filename
+func (g *generator) randomFilename() string {
+	words := g.randomWords()
+	num := g.rng.Intn(1000)
+
+	return fmt.Sprintf("%s_%d", words, num)
+}
 
-	content := fmt.Sprintf("Generated at: %s\n\n", time.Now().Format(time.RFC3339))
+// randomContent generates random content for the file. It is stamped with
+// the seed rather than the time, so that a seeded run is reproducible.
+func (g *generator) randomContent() string {
+	content := fmt.Sprintf("Generated with seed: %d\n\n", g.seed)
 
 	// Add 3-7 random sentences
-	numSentences := 3 + rand.Intn(5)
+	numSentences := 3 + g.rng.Intn(5)
 	for i := 0; i < numSentences; i++ {
-		sentence := sentences[rand.Intn(len(sentences))]
+		sentence := sentences[g.rng.Intn(len(sentences))]
 		content += sentence + "\n"
 	}
 
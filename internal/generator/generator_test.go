@@ -0,0 +1,233 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateRandomFilesDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{
+			name: "flat",
+			opts: Options{MinDepth: 1, MaxDepth: 1, Fanout: 1, Count: 20, Seed: 42},
+		},
+		{
+			name: "text",
+			opts: Options{MinDepth: 2, MaxDepth: 3, Fanout: 2, Count: 40, Seed: 42},
+		},
+		{
+			name: "spread with random dir names",
+			opts: Options{MinDepth: 1, MaxDepth: 4, Fanout: 3, Spread: true, DirNames: "random", Count: 40, Seed: 11},
+		},
+		{
+			name: "sizes",
+			opts: Options{MinDepth: 1, MaxDepth: 3, Fanout: 3, Spread: true, MinSize: 16, MaxSize: 8 << 10, SizeDist: DistLogNormal, TotalSize: 128 << 10, Seed: 7},
+		},
+		{
+			name: "random content",
+			opts: Options{MinDepth: 1, MaxDepth: 2, Fanout: 2, Count: 10, Size: 4 << 10, Content: ContentRandom, Seed: 8},
+		},
+		{
+			name: "sparse content",
+			opts: Options{MinDepth: 1, MaxDepth: 2, Fanout: 2, Count: 5, MinSize: 1 << 20, MaxSize: 4 << 20, SizeDist: DistUniform, Content: ContentSparse, Seed: 9},
+		},
+		{
+			name: "types",
+			opts: Options{MinDepth: 2, MaxDepth: 2, Fanout: 3, Count: 40, Types: []TypeWeight{{TypeText, 3}, {TypePNG, 1}, {TypeZip, 1}, {TypeMP3, 1}, {TypePDF, 1}}, Seed: 5},
+		},
+		{
+			name: "types and duplicates",
+			opts: Options{
+				MinDepth: 2, MaxDepth: 2, Fanout: 3, Count: 60, Seed: 5,
+				Types:    []TypeWeight{{TypeText, 3}, {TypePNG, 1}, {TypeZip, 1}, {TypeMP3, 1}},
+				DupRatio: 0.2, HardlinkRatio: 0.1, SameSizeRatio: 0.1, LastByteRatio: 0.1,
+			},
+		},
+		{
+			name: "duplicates to a total size",
+			opts: Options{MinDepth: 1, MaxDepth: 2, Fanout: 2, MinSize: 1, MaxSize: 4 << 10, TotalSize: 64 << 10, DupRatio: 0.3, HardlinkRatio: 0.3, Seed: 6},
+		},
+		{
+			name: "edge names",
+			opts: Options{MinDepth: 1, MaxDepth: 2, Fanout: 2, Count: 50, Names: NamesEdge, DupRatio: 0.2, Seed: 3},
+		},
+		{
+			name: "dir name list",
+			opts: Options{MinDepth: 3, MaxDepth: 3, Fanout: 2, DirNameList: []string{"a", "b"}, Count: 10, Seed: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The same seed builds the same tree with any number of workers
+			var first *Manifest
+			for _, jobs := range []int{1, 1, 4, 16} {
+				opts := tt.opts
+				opts.Jobs = jobs
+				dir := t.TempDir()
+				manifest, err := CreateRandomFiles(dir, opts)
+				if err != nil {
+					t.Fatalf("jobs=%d: %v", jobs, err)
+				}
+				checkManifest(t, dir, manifest, opts)
+
+				if first == nil {
+					first = manifest
+				} else if !reflect.DeepEqual(manifest, first) {
+					t.Errorf("jobs=%d: manifest differs from the first run", jobs)
+				}
+			}
+		})
+	}
+}
+
+// checkManifest compares the manifest of a run with the files on disk
+func checkManifest(t *testing.T, dir string, m *Manifest, opts Options) {
+	t.Helper()
+
+	if m.Seed != opts.Seed {
+		t.Errorf("manifest seed = %d, want %d", m.Seed, opts.Seed)
+	}
+	if opts.Count > 0 && len(m.Files) != opts.Count {
+		t.Errorf("manifest lists %d files, want %d", len(m.Files), opts.Count)
+	}
+	if (m.Groups != nil) != opts.derives() {
+		t.Errorf("manifest groups = %v, want them only with derived files", m.Groups)
+	}
+
+	depth := 1
+	for _, d := range m.Dirs {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(d)))
+		if err != nil || !info.IsDir() {
+			t.Errorf("directory %s: %v", d, err)
+		}
+		depth = max(depth, strings.Count(d, "/")+2)
+	}
+	// The deep branch of an edge tree goes far below MaxDepth
+	if depth != opts.MaxDepth && opts.Names != NamesEdge {
+		t.Errorf("tree is %d levels deep, want %d", depth, opts.MaxDepth)
+	}
+
+	var total int64
+	seen := make(map[string]bool)
+	for _, file := range m.Files {
+		if seen[file.Path] {
+			t.Errorf("%s is listed twice", file.Path)
+		}
+		seen[file.Path] = true
+
+		path := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if len(filepath.Base(path)) > MaxNameBytes {
+			t.Errorf("%s: name longer than %d bytes", file.Path, MaxNameBytes)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", file.Path, err)
+			continue
+		}
+		sum := sha256.Sum256(content)
+		if int64(len(content)) != file.Size || hex.EncodeToString(sum[:]) != file.SHA256 {
+			t.Errorf("%s: size %d and sha256 %x on disk, manifest has %d and %s", file.Path, len(content), sum, file.Size, file.SHA256)
+		}
+		if file.Type == "hardlink" {
+			info, _ := os.Stat(path)
+			target, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file.Target)))
+			if err != nil || !os.SameFile(info, target) {
+				t.Errorf("%s is not a hard link to %s", file.Path, file.Target)
+			}
+			continue
+		}
+		if opts.Size > 0 && file.Size != opts.Size {
+			t.Errorf("%s: size %d, want %d", file.Path, file.Size, opts.Size)
+		}
+		total += file.Size
+	}
+
+	if opts.TotalSize > 0 && total != opts.TotalSize {
+		t.Errorf("files add up to %d bytes, want %d", total, opts.TotalSize)
+	}
+}
+
+func TestCreateRandomFilesSeeds(t *testing.T) {
+	opts := Options{MinDepth: 1, MaxDepth: 2, Fanout: 2, Count: 10}
+
+	opts.Seed = 1
+	a, err := CreateRandomFiles(t.TempDir(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Seed = 2
+	b, err := CreateRandomFiles(t.TempDir(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(a.Files, b.Files) {
+		t.Error("seeds 1 and 2 built the same tree")
+	}
+
+	// Without a seed one is picked and reported
+	opts.Seed = 0
+	c, err := CreateRandomFiles(t.TempDir(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Seed == 0 {
+		t.Error("no seed reported in the manifest")
+	}
+}
+
+func TestCreateRandomFilesKeepsExisting(t *testing.T) {
+	opts := Options{MinDepth: 1, MaxDepth: 1, Fanout: 1, Count: 30, Seed: 9}
+	dir := t.TempDir()
+	first, err := CreateRandomFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same seed again draws the same names, which are all taken now
+	opts.Jobs = 8
+	second, err := CreateRandomFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkManifest(t, dir, first, opts)
+	checkManifest(t, dir, second, opts)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2*opts.Count {
+		t.Errorf("%d files in the directory, want %d", len(entries), 2*opts.Count)
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	manifest, err := CreateRandomFiles(dir, Options{MinDepth: 2, MaxDepth: 2, Fanout: 2, Count: 5, Seed: 3, Manifest: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written Manifest
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&written, manifest) {
+		t.Errorf("manifest file = %+v, want %+v", written, *manifest)
+	}
+}
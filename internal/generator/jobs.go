@@ -0,0 +1,185 @@
+package generator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	randv2 "math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// job is one file of a random tree. Names, sizes and seeds are drawn in
+// order before a job is queued, so the tree comes out the same with any
+// number of workers.
+type job struct {
+	path string
+	// fg draws the content of the file
+	fg       *generator
+	fileType FileType
+	// data is the content when it had to be drawn up front; without it the
+	// worker draws the content of fileType
+	data *fileData
+	// kind and source are set for a file derived from an earlier one
+	kind   variant
+	source *createdFile
+	// created is set when later files may be derived from this one
+	created *createdFile
+	result  *ManifestFile
+}
+
+// pool creates the files of jobs on a number of workers; the first error
+// stops it
+type pool struct {
+	jobs chan *job
+	wg   sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+	// pending are the paths handed out but not created yet
+	pending map[string]bool
+}
+
+// startPool starts opts.Jobs workers, at least one
+func (g *generator) startPool(opts Options) *pool {
+	workers := max(opts.Jobs, 1)
+	p := &pool{jobs: make(chan *job, workers*4), pending: make(map[string]bool)}
+	for w := 0; w < workers; w++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for j := range p.jobs {
+				err := g.run(j, opts)
+				p.mu.Lock()
+				delete(p.pending, j.path)
+				if err != nil && p.err == nil {
+					p.err = err
+				}
+				p.mu.Unlock()
+				// Files derived from this one may go ahead, or fail, now
+				if j.created != nil {
+					close(j.created.done)
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// failed reports whether a worker has failed
+func (p *pool) failed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err != nil
+}
+
+// wait waits for the queued jobs and returns the first error
+func (p *pool) wait() error {
+	close(p.jobs)
+	p.wg.Wait()
+	return p.err
+}
+
+// reserve returns a path in dir for base and ext that no file has and no
+// other job will get. A taken name gets a number: name_2.txt, name_3.txt.
+func (p *pool) reserve(dir, base, ext string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	path := filepath.Join(dir, fitName(base, ext))
+	for n := 2; p.taken(path); n++ {
+		path = filepath.Join(dir, fitName(base, fmt.Sprintf("_%d%s", n, ext)))
+	}
+	p.pending[path] = true
+	return path
+}
+
+func (p *pool) taken(path string) bool {
+	if p.pending[path] {
+		return true
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// fork returns a generator for the content of one file, seeded from g
+func (g *generator) fork() *generator {
+	return &generator{rng: rand.New(pcgSource{randv2.NewPCG(g.rng.Uint64(), 0)}), seed: g.seed}
+}
+
+// pcgSource lets math/rand draw from a PCG generator, which is much
+// cheaper to seed than its own source: a fork per file would otherwise
+// take longer than writing a small file
+type pcgSource struct{ *randv2.PCG }
+
+func (s pcgSource) Int63() int64 { return int64(s.Uint64() >> 1) }
+
+func (s pcgSource) Seed(seed int64) { s.PCG.Seed(uint64(seed), 0) }
+
+// run creates the file of a job; it never replaces an existing file
+func (g *generator) run(j *job, opts Options) error {
+	// The content is drawn here; drop what is no longer needed once done
+	defer func() { j.fg, j.data = nil, nil }()
+
+	rel, err := filepath.Rel(g.baseDir, j.path)
+	if err != nil {
+		return err
+	}
+
+	source := j.source
+	if source != nil {
+		<-source.done
+		if source.sha256 == "" {
+			return fmt.Errorf("original %s of %s was not created", source.path, j.path)
+		}
+	}
+
+	if source != nil && j.kind == variantHardlink {
+		if err := os.Link(source.path, j.path); err != nil {
+			return fmt.Errorf("failed to create hardlink %s: %v", j.path, err)
+		}
+		target, _ := filepath.Rel(g.baseDir, source.path)
+		j.result = &ManifestFile{Path: filepath.ToSlash(rel), Size: source.size, SHA256: source.sha256, Type: "hardlink", Target: filepath.ToSlash(target)}
+		fmt.Printf("Created hardlink: %s -> %s\n", j.path, source.path)
+		return nil
+	}
+
+	data := j.data
+	switch {
+	case data != nil:
+	case source != nil:
+		var original *os.File
+		if data, original, err = variantData(j.kind, source); err != nil {
+			return err
+		}
+		if original != nil {
+			defer original.Close()
+		}
+	default:
+		if _, data, err = j.fg.fileContent(j.fileType, opts, 0); err != nil {
+			return err
+		}
+	}
+
+	size, sum, err := writeData(j.path, data, os.O_EXCL)
+	if err != nil {
+		return err
+	}
+	if source != nil && j.kind == variantSameSize && hex.EncodeToString(sum) == source.sha256 {
+		if sum, err = flipFirstByte(j.path); err != nil {
+			return err
+		}
+	}
+
+	j.result = &ManifestFile{Path: filepath.ToSlash(rel), Size: size, SHA256: hex.EncodeToString(sum)}
+	if j.created != nil {
+		j.created.sha256 = j.result.SHA256
+	}
+	if source != nil {
+		fmt.Printf("Created %s: %s (from %s)\n", j.kind, j.path, source.path)
+	} else {
+		fmt.Printf("Created: %s\n", j.path)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NameProfile is how generated files are named
+type NameProfile string
+
+const (
+	// NamesSimple gives adjective_noun_NNN names
+	NamesSimple NameProfile = "simple"
+	// NamesEdge gives the names that break tools in the field: Unicode in
+	// NFC and NFD, emoji, spaces, leading dots and dashes, names at the
+	// 255-byte limit, names differing only by case and glob metacharacters.
+	// The tree also gets one branch nested almost to the path length limit.
+	NamesEdge NameProfile = "edge"
+)
+
+// ParseNameProfile converts a flag value into a NameProfile
+func ParseNameProfile(s string) (NameProfile, error) {
+	switch p := NameProfile(strings.ToLower(s)); p {
+	case "", NamesSimple, NamesEdge:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid name profile '%s' (expected simple or edge)", s)
+}
+
+// MaxNameBytes is the longest file name most filesystems accept
+const MaxNameBytes = 255
+
+// maxPath is the longest path the deep branch of an edge tree goes to:
+// PATH_MAX less its terminating NUL, which is 1024 on macOS and the BSDs
+func maxPath() int {
+	if runtime.GOOS == "linux" {
+		return 4095
+	}
+	return 1023
+}
+
+var (
+	// accentedWords are written in NFC here and converted to NFD as needed
+	accentedWords = []string{"café", "naïve", "résumé", "Zürich", "Ångström", "señor", "façade", "crème brûlée", "São Paulo", "Łódź"}
+	otherScripts  = []string{"日本語", "文件", "Москва", "Αθήνα", "שלום", "مرحبا", "한국어", "ไทย"}
+	emoji         = []string{"🎉", "📁", "🚀", "👍🏽", "👩‍💻", "🇯🇵", "❤️", "🐛"}
+	globPatterns  = []string{"[%s]", "%s*", "*%s", "%s?", "?%s", "[a-z]%s", "%s[1]", "{%s,x}", "%s[!0-9]"}
+)
+
+// edgeName draws the base name of a file in dir for the edge profile
+func (g *generator) edgeName(dir string) string {
+	words := g.randomWords()
+	num := g.rng.Intn(1000)
+
+	var name string
+	switch kind := g.rng.Intn(10); kind {
+	case 0:
+		name = fmt.Sprintf("%s %d", accentedWords[g.rng.Intn(len(accentedWords))], num)
+	case 1:
+		name = norm.NFD.String(fmt.Sprintf("%s %d", accentedWords[g.rng.Intn(len(accentedWords))], num))
+	case 2:
+		name = fmt.Sprintf("%s_%s_%d", otherScripts[g.rng.Intn(len(otherScripts))], words, num)
+	case 3:
+		name = fmt.Sprintf("%s %s %d", emoji[g.rng.Intn(len(emoji))], words, num)
+	case 4:
+		name = [...]string{" %s_%d", "%s_%d ", "%s  %d", "%s %d  "}[g.rng.Intn(4)]
+		name = fmt.Sprintf(name, words, num)
+	case 5:
+		name = fmt.Sprintf("%s%s_%d", [...]string{".", "..", "._"}[g.rng.Intn(3)], words, num)
+	case 6:
+		name = fmt.Sprintf("%s%s_%d", [...]string{"-", "--", "-rf "}[g.rng.Intn(3)], words, num)
+	case 7:
+		name = fmt.Sprintf(globPatterns[g.rng.Intn(len(globPatterns))], fmt.Sprintf("%s_%d", words, num))
+	case 8:
+		// Long enough to be cut to the limit, in ASCII or multibyte UTF-8
+		unit := [...]string{words + "_", "é", "日本"}[g.rng.Intn(3)]
+		name = fmt.Sprintf("%d_%s", num, strings.Repeat(unit, MaxNameBytes/len(unit)+1))
+	case 9:
+		// The same name as an earlier file here, in another case
+		name = fmt.Sprintf("%s_%d", strings.ToUpper(words), num)
+		if earlier := g.edgeNames[dir]; len(earlier) > 0 {
+			original := earlier[g.rng.Intn(len(earlier))]
+			if swapped := swapCase(original); swapped != original {
+				name = swapped
+			}
+		}
+	}
+
+	g.edgeNames[dir] = append(g.edgeNames[dir], name)
+	return name
+}
+
+// swapCase turns upper case letters into lower case and the other way round
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// fitName joins base and ext, shortening base at a character boundary so
+// the name stays within MaxNameBytes
+func fitName(base, ext string) string {
+	limit := MaxNameBytes - len(ext)
+	if len(base) <= limit {
+		return base + ext
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(base[cut]) {
+		cut--
+	}
+	return base[:cut] + ext
+}
+
+// deepBranch creates a chain of nested directories under root whose path
+// leaves just enough room for a file name of MaxNameBytes, and returns the
+// deepest one, or "" when root is already too long. Where the system
+// refuses to go further, the chain stops early.
+func (g *generator) deepBranch(root string) (string, error) {
+	limit := maxPath() - 1 - MaxNameBytes
+	deepest := ""
+	for parent, level := root, 1; ; level++ {
+		path := filepath.Join(parent, fmt.Sprintf("deep_%d", level))
+		if len(path) > limit {
+			break
+		}
+		if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+			if deepest == "" {
+				return "", err
+			}
+			break
+		}
+		rel, _ := filepath.Rel(g.baseDir, path)
+		g.manifest.Dirs = append(g.manifest.Dirs, filepath.ToSlash(rel))
+		parent, deepest = path, path
+	}
+	return deepest, nil
+}
@@ -0,0 +1,39 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFitName(t *testing.T) {
+	tests := []struct {
+		base, ext string
+		want      string
+	}{
+		{"short", ".txt", "short.txt"},
+		{strings.Repeat("a", 251), ".txt", strings.Repeat("a", 251) + ".txt"},
+		{strings.Repeat("a", 300), ".txt", strings.Repeat("a", 251) + ".txt"},
+		// Multibyte characters are never cut in half
+		{strings.Repeat("é", 200), ".txt", strings.Repeat("é", 125) + ".txt"},
+		{strings.Repeat("日本", 100), "_2.tar.gz", strings.Repeat("日本", 41) + "_2.tar.gz"},
+	}
+
+	for _, tt := range tests {
+		got := fitName(tt.base, tt.ext)
+		if got != tt.want || len(got) > MaxNameBytes || !utf8.ValidString(got) {
+			t.Errorf("fitName(%q, %q) = %q (%d bytes), want %q", tt.base, tt.ext, got, len(got), tt.want)
+		}
+	}
+}
+
+func TestParseNameProfile(t *testing.T) {
+	for in, want := range map[string]NameProfile{"": "", "simple": NamesSimple, "EDGE": NamesEdge} {
+		if got, err := ParseNameProfile(in); err != nil || got != want {
+			t.Errorf("ParseNameProfile(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseNameProfile("weird"); err == nil {
+		t.Error("ParseNameProfile(\"weird\") did not fail")
+	}
+}
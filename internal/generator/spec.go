@@ -0,0 +1,448 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes an exact tree to build. Entries are created in order,
+// links after everything else, so a link may point to a later entry.
+type Spec struct {
+	// Seed seeds whatever the entries leave to chance; 0 picks a random seed
+	Seed    int64       `json:"seed,omitempty" yaml:"seed,omitempty"`
+	Entries []SpecEntry `json:"entries" yaml:"entries"`
+}
+
+// SpecEntry is one entry of a Spec. Which fields are used depends on Type:
+//
+//	file     Content (literal), or Generate with Size or MinSize/MaxSize
+//	dir      nothing; missing parents are created for every entry anyway
+//	symlink  Target, written as given
+//	hardlink Target, a path in the tree
+//	random   a random tree under Path, from Count, TotalSize, the depth,
+//	         fanout and size fields, Generate (a content mode), Types and
+//	         Names
+//
+// Mode and MTime apply to files, directories and the files of a random
+// entry. MTime is a time such as "2024-05-01" or "2024-05-01T10:00:00Z", or
+// a range "2020-01-01..2024-12-31" to draw one from.
+type SpecEntry struct {
+	Path     string   `json:"path" yaml:"path"`
+	Type     string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Content  *string  `json:"content,omitempty" yaml:"content,omitempty"`
+	Generate string   `json:"generate,omitempty" yaml:"generate,omitempty"`
+	Size     SpecSize `json:"size,omitempty" yaml:"size,omitempty"`
+	MinSize  SpecSize `json:"min_size,omitempty" yaml:"min_size,omitempty"`
+	MaxSize  SpecSize `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	SizeDist string   `json:"size_dist,omitempty" yaml:"size_dist,omitempty"`
+	Target   string   `json:"target,omitempty" yaml:"target,omitempty"`
+	Mode     string   `json:"mode,omitempty" yaml:"mode,omitempty"`
+	MTime    string   `json:"mtime,omitempty" yaml:"mtime,omitempty"`
+
+	Count     int      `json:"count,omitempty" yaml:"count,omitempty"`
+	TotalSize SpecSize `json:"total_size,omitempty" yaml:"total_size,omitempty"`
+	Depth     int      `json:"depth,omitempty" yaml:"depth,omitempty"`
+	MinDepth  int      `json:"min_depth,omitempty" yaml:"min_depth,omitempty"`
+	MaxDepth  int      `json:"max_depth,omitempty" yaml:"max_depth,omitempty"`
+	Fanout    int      `json:"fanout,omitempty" yaml:"fanout,omitempty"`
+	DirNames  string   `json:"dir_names,omitempty" yaml:"dir_names,omitempty"`
+	Spread    bool     `json:"spread,omitempty" yaml:"spread,omitempty"`
+	Types     string   `json:"types,omitempty" yaml:"types,omitempty"`
+	Names     string   `json:"names,omitempty" yaml:"names,omitempty"`
+}
+
+// SpecSize is a size in a spec, written as a number of bytes or a string
+// such as "4K" or "1.5MiB"
+type SpecSize string
+
+// UnmarshalJSON accepts both numbers and strings
+func (s *SpecSize) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return fmt.Errorf("invalid size %s", data)
+		}
+		text = number.String()
+	}
+	*s = SpecSize(text)
+	return nil
+}
+
+func (s SpecSize) bytes() (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return ParseSize(string(s))
+}
+
+// LoadSpec reads a tree spec from a .json, .yaml or .yml file
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %v", err)
+	}
+
+	spec := &Spec{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, spec)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, spec)
+	default:
+		return nil, fmt.Errorf("unknown spec format '%s' (expected .json, .yaml or .yml)", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spec %s: %v", path, err)
+	}
+	if len(spec.Entries) == 0 {
+		return nil, fmt.Errorf("spec %s has no entries", path)
+	}
+	return spec, nil
+}
+
+// attributes are a Mode and MTime to apply once the tree is built
+type attributes struct {
+	path  string
+	isDir bool
+	mode  os.FileMode
+	mtime time.Time
+}
+
+// BuildSpec creates the tree described by spec under baseDir. A seed other
+// than 0 overrides the spec's seed; manifest, when set, is where the
+// manifest is written.
+func BuildSpec(baseDir string, spec *Spec, seed int64, manifest string) (*Manifest, error) {
+	if seed == 0 {
+		seed = spec.Seed
+	}
+	g, err := newGenerator(baseDir, seed)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(g.baseDir, 0755); err != nil {
+		return nil, err
+	}
+
+	var links []SpecEntry
+	var attrs []attributes
+	for i, entry := range spec.Entries {
+		entryAttrs, err := g.buildEntry(entry, &links)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d (%s): %v", i+1, entry.Path, err)
+		}
+		attrs = append(attrs, entryAttrs...)
+	}
+
+	for _, entry := range links {
+		if err := g.buildLink(entry); err != nil {
+			return nil, fmt.Errorf("entry %s: %v", entry.Path, err)
+		}
+	}
+
+	// Directories last and deepest first: creating entries changes a
+	// directory's mtime, and a read-only directory cannot be filled
+	sort.SliceStable(attrs, func(i, j int) bool {
+		if attrs[i].isDir != attrs[j].isDir {
+			return !attrs[i].isDir
+		}
+		return attrs[i].isDir && strings.Count(attrs[i].path, string(filepath.Separator)) > strings.Count(attrs[j].path, string(filepath.Separator))
+	})
+	for _, a := range attrs {
+		if a.mode != 0 {
+			if err := os.Chmod(a.path, a.mode); err != nil {
+				return nil, err
+			}
+		}
+		if !a.mtime.IsZero() {
+			if err := os.Chtimes(a.path, a.mtime, a.mtime); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if manifest != "" {
+		if err := WriteManifest(manifest, g.manifest); err != nil {
+			return nil, err
+		}
+	}
+	return g.manifest, nil
+}
+
+// buildEntry creates a file, directory or random tree, or queues a link,
+// and returns the attributes to apply afterwards
+func (g *generator) buildEntry(entry SpecEntry, links *[]SpecEntry) ([]attributes, error) {
+	fullPath, err := g.specPath(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := parseMode(entry.Mode)
+	if err != nil {
+		return nil, err
+	}
+	mtime := func() (time.Time, error) { return g.parseMTime(entry.MTime) }
+
+	kind := strings.ToLower(entry.Type)
+	switch kind {
+	case "symlink", "hardlink":
+		if entry.Target == "" {
+			return nil, fmt.Errorf("a %s needs a target", kind)
+		}
+		*links = append(*links, entry)
+		return nil, nil
+
+	case "dir":
+		if err := g.mkdirAll(fullPath); err != nil {
+			return nil, err
+		}
+		t, err := mtime()
+		return []attributes{{path: fullPath, isDir: true, mode: mode, mtime: t}}, err
+
+	case "random":
+		opts, err := entry.randomOptions()
+		if err != nil {
+			return nil, err
+		}
+		if err := g.mkdirAll(fullPath); err != nil {
+			return nil, err
+		}
+		first := len(g.manifest.Files)
+		if err := g.generate(fullPath, opts); err != nil {
+			return nil, err
+		}
+
+		var attrs []attributes
+		for _, file := range g.manifest.Files[first:] {
+			t, err := mtime()
+			if err != nil {
+				return nil, err
+			}
+			attrs = append(attrs, attributes{path: filepath.Join(g.baseDir, filepath.FromSlash(file.Path)), mode: mode, mtime: t})
+		}
+		return attrs, nil
+
+	case "", "file":
+		if err := g.mkdirAll(filepath.Dir(fullPath)); err != nil {
+			return nil, err
+		}
+		data, err := g.entryContent(entry)
+		if err != nil {
+			return nil, err
+		}
+		if err := g.create(fullPath, data); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Created: %s\n", fullPath)
+		t, err := mtime()
+		return []attributes{{path: fullPath, mode: mode, mtime: t}}, err
+	}
+
+	return nil, fmt.Errorf("unknown type '%s' (expected file, dir, symlink, hardlink or random)", entry.Type)
+}
+
+// entryContent returns the literal or generated content of a file entry
+func (g *generator) entryContent(entry SpecEntry) (*fileData, error) {
+	if entry.Content != nil {
+		if entry.Generate != "" || entry.Size != "" || entry.MinSize != "" || entry.MaxSize != "" {
+			return nil, fmt.Errorf("content cannot be combined with generate or sizes")
+		}
+		return &fileData{r: strings.NewReader(*entry.Content), size: int64(len(*entry.Content))}, nil
+	}
+
+	opts, err := entry.sizeOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts.Count, opts.TotalSize = 1, 0
+
+	// generate is either a file type or a content mode
+	fileType := TypeText
+	if entry.Generate != "" {
+		if types, err := ParseTypes(entry.Generate); err == nil && len(types) == 1 {
+			fileType = types[0].Type
+		} else if opts.Content, err = ParseContentMode(entry.Generate); err != nil {
+			return nil, fmt.Errorf("invalid generate '%s' (expected a file type or text, random, compressible or sparse)", entry.Generate)
+		}
+	}
+	if fileType != TypeText && opts.sized() {
+		return nil, fmt.Errorf("%s files are generated at their natural size", fileType)
+	}
+	if err := opts.validateSizes(); err != nil {
+		return nil, err
+	}
+
+	_, data, err := g.fileContent(fileType, opts, 0)
+	return data, err
+}
+
+// sizeOptions reads the size fields of an entry
+func (e SpecEntry) sizeOptions() (Options, error) {
+	var opts Options
+	var err error
+	for _, field := range []struct {
+		value SpecSize
+		dest  *int64
+	}{{e.Size, &opts.Size}, {e.MinSize, &opts.MinSize}, {e.MaxSize, &opts.MaxSize}, {e.TotalSize, &opts.TotalSize}} {
+		if *field.dest, err = field.value.bytes(); err != nil {
+			return opts, err
+		}
+	}
+	opts.SizeDist, err = ParseDistribution(e.SizeDist)
+	return opts, err
+}
+
+// randomOptions converts a random entry into generator options
+func (e SpecEntry) randomOptions() (Options, error) {
+	opts, err := e.sizeOptions()
+	if err != nil {
+		return opts, err
+	}
+	if opts.Content, err = ParseContentMode(e.Generate); err != nil {
+		return opts, err
+	}
+	if e.Types != "" {
+		if opts.Types, err = ParseTypes(e.Types); err != nil {
+			return opts, err
+		}
+	}
+	if opts.Names, err = ParseNameProfile(e.Names); err != nil {
+		return opts, err
+	}
+
+	depth := max(e.Depth, 1)
+	opts.MinDepth, opts.MaxDepth = e.MinDepth, e.MaxDepth
+	if opts.MinDepth == 0 {
+		opts.MinDepth = depth
+	}
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = max(depth, opts.MinDepth)
+	}
+	opts.Fanout = max(e.Fanout, 1)
+	opts.Spread = e.Spread
+	opts.Count = e.Count
+	if opts.Count == 0 && opts.TotalSize == 0 {
+		opts.Count = 5
+	}
+	if e.DirNames == "" || e.DirNames == "level" || e.DirNames == "random" {
+		opts.DirNames = e.DirNames
+	} else {
+		opts.DirNameList = strings.Split(e.DirNames, ",")
+	}
+	return opts, opts.Validate()
+}
+
+// buildLink creates a symbolic or hard link entry
+func (g *generator) buildLink(entry SpecEntry) error {
+	fullPath, err := g.specPath(entry.Path)
+	if err != nil {
+		return err
+	}
+	if err := g.mkdirAll(filepath.Dir(fullPath)); err != nil {
+		return err
+	}
+	rel := filepath.ToSlash(strings.TrimPrefix(fullPath, g.baseDir+string(filepath.Separator)))
+
+	if strings.ToLower(entry.Type) == "symlink" {
+		if err := os.Symlink(filepath.FromSlash(entry.Target), fullPath); err != nil {
+			return err
+		}
+		g.manifest.Files = append(g.manifest.Files, ManifestFile{Path: rel, Type: "symlink", Target: entry.Target})
+		fmt.Printf("Created symlink: %s -> %s\n", fullPath, entry.Target)
+		return nil
+	}
+
+	target, err := g.specPath(entry.Target)
+	if err != nil {
+		return err
+	}
+	return g.link(target, fullPath)
+}
+
+// specPath resolves a slash separated spec path inside the base directory
+func (g *generator) specPath(p string) (string, error) {
+	clean := path.Clean(strings.TrimSuffix(p, "/"))
+	if p == "" || path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("path must be relative and inside the tree")
+	}
+	return filepath.Join(g.baseDir, filepath.FromSlash(clean)), nil
+}
+
+// mkdirAll creates dir and its missing parents, recording each in the
+// manifest
+func (g *generator) mkdirAll(dir string) error {
+	rel, err := filepath.Rel(g.baseDir, dir)
+	if err != nil || rel == "." {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := g.mkdirAll(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	g.manifest.Dirs = append(g.manifest.Dirs, filepath.ToSlash(rel))
+	return nil
+}
+
+// parseMode parses an octal permission mode such as "0644"
+func parseMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid mode '%s' (expected octal permissions such as 0644)", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// mtimeLayouts are the time formats accepted in a spec
+var mtimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseMTime parses a time or draws one from a "from..to" range
+func (g *generator) parseMTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	from, to, isRange := strings.Cut(s, "..")
+
+	parse := func(value string) (time.Time, error) {
+		for _, layout := range mtimeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid mtime '%s' (expected e.g. 2024-05-01 or 2024-05-01T10:00:00Z)", value)
+	}
+
+	start, err := parse(from)
+	if err != nil || !isRange {
+		return start, err
+	}
+	end, err := parse(to)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !end.After(start) {
+		return time.Time{}, fmt.Errorf("empty mtime range '%s'", s)
+	}
+	// Both ends are included; a span too long for a Duration is capped
+	span := int64(end.Sub(start))
+	if span < math.MaxInt64 {
+		span++
+	}
+	return start.Add(time.Duration(g.rng.Int63n(span))), nil
+}
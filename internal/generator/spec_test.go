@@ -0,0 +1,235 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSpec writes a spec file named name and returns its path
+func writeSpec(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []SpecEntry
+		wantErr string
+	}{
+		{
+			name:    "json sizes as numbers and strings",
+			file:    "spec.json",
+			content: `{"seed": 3, "entries": [{"path": "a", "size": 1024}, {"path": "b", "size": "4K", "max_size": 2.5}]}`,
+			want:    []SpecEntry{{Path: "a", Size: "1024"}, {Path: "b", Size: "4K", MaxSize: "2.5"}},
+		},
+		{
+			name:    "yaml",
+			file:    "spec.yml",
+			content: "entries:\n  - path: a\n    size: 1024\n  - path: b\n    content: \"\"\n",
+			want:    []SpecEntry{{Path: "a", Size: "1024"}, {Path: "b", Content: new(string)}},
+		},
+		{
+			name:    "json size of another type",
+			file:    "spec.json",
+			content: `{"entries": [{"path": "a", "size": [1]}]}`,
+			wantErr: "invalid size",
+		},
+		{
+			name:    "no entries",
+			file:    "spec.yaml",
+			content: "seed: 1\n",
+			wantErr: "has no entries",
+		},
+		{
+			name:    "unknown format",
+			file:    "spec.toml",
+			content: "",
+			wantErr: "unknown spec format",
+		},
+		{
+			name:    "syntax error",
+			file:    "spec.json",
+			content: `{"entries": [`,
+			wantErr: "failed to parse spec",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := LoadSpec(writeSpec(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadSpec() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(spec.Entries, tt.want) {
+				t.Errorf("LoadSpec() entries = %+v, want %+v", spec.Entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0644", 0o644, false},
+		{"755", 0o755, false},
+		{"0o600", 0o600, false},
+		{"0777", 0o777, false},
+		{"01777", 0, true},
+		{"0849", 0, true},
+		{"rw-r--r--", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMode(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseMode(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseMTime(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in       string
+		from, to time.Time
+		wantErr  string
+	}{
+		{in: ""},
+		{in: "2024-05-01", from: day, to: day},
+		{in: "2024-05-01T10:00:00Z", from: day.Add(10 * time.Hour), to: day.Add(10 * time.Hour)},
+		{in: "2024-05-01 10:00:00", from: day.Add(10 * time.Hour), to: day.Add(10 * time.Hour)},
+		{in: "2024-05-01..2024-05-31", from: day, to: day.AddDate(0, 0, 30)},
+		{in: "2024-05-01 .. 2024-05-02", from: day, to: day.AddDate(0, 0, 1)},
+		// Ranges shorter than a second, down to a nanosecond
+		{in: "2024-05-01T00:00:00Z..2024-05-01T00:00:00.5Z", from: day, to: day.Add(500 * time.Millisecond)},
+		{in: "2024-05-01T00:00:00Z..2024-05-01T00:00:00.000000001Z", from: day, to: day.Add(1)},
+		// Longer than a time.Duration can hold
+		{in: "1700-01-01..2200-01-01", from: time.Date(1700, 1, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "2024-05-01..2024-05-01", wantErr: "empty mtime range"},
+		{in: "2024-05-02..2024-05-01", wantErr: "empty mtime range"},
+		{in: "yesterday", wantErr: "invalid mtime"},
+		{in: "2024-05-01..later", wantErr: "invalid mtime"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			g, err := newGenerator(t.TempDir(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Draw a few times to cover more of a range
+			for i := 0; i < 20; i++ {
+				got, err := g.parseMTime(tt.in)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("parseMTime(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("parseMTime(%q) error = %v", tt.in, err)
+				}
+				if got.Before(tt.from) || got.After(tt.to) {
+					t.Fatalf("parseMTime(%q) = %v, want between %v and %v", tt.in, got, tt.from, tt.to)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildSpec(t *testing.T) {
+	spec := &Spec{Seed: 7, Entries: []SpecEntry{
+		{Path: "music", Type: "dir", MTime: "2020-05-01"},
+		{Path: "music/album/01 intro.mp3", Generate: "mp3"},
+		{Path: "notes.txt", Content: ptr("hello\n"), Mode: "0600", MTime: "2021-01-01T00:00:00Z..2021-01-01T00:00:00.5Z"},
+		{Path: "intro-copy.mp3", Type: "hardlink", Target: "music/album/01 intro.mp3"},
+		{Path: "latest", Type: "symlink", Target: "music/album"},
+		{Path: "blob.bin", Generate: "random", Size: "4K"},
+		{Path: "photos", Type: "random", Count: 6, Types: "png,jpeg", Fanout: 2, Depth: 2},
+	}}
+
+	dir := t.TempDir()
+	first, err := BuildSpec(dir, spec, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := BuildSpec(t.TempDir(), spec, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, again) {
+		t.Error("the same spec and seed built different trees")
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "notes.txt"))
+	if err != nil || string(content) != "hello\n" {
+		t.Errorf("notes.txt = %q, %v", content, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "notes.txt"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("notes.txt mode = %v, %v", info.Mode().Perm(), err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "music")); err != nil || !info.ModTime().Equal(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("music mtime = %v, %v", info.ModTime(), err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "latest")); err != nil || target != filepath.FromSlash("music/album") {
+		t.Errorf("latest -> %q, %v", target, err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "blob.bin")); err != nil || info.Size() != 4096 {
+		t.Errorf("blob.bin is not 4096 bytes: %v", err)
+	}
+}
+
+func TestBuildSpecErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   SpecEntry
+		wantErr string
+	}{
+		{"outside the tree", SpecEntry{Path: "../x"}, "inside the tree"},
+		{"absolute", SpecEntry{Path: "/x"}, "inside the tree"},
+		{"unknown type", SpecEntry{Path: "x", Type: "fifo"}, "unknown type"},
+		{"link without target", SpecEntry{Path: "x", Type: "symlink"}, "needs a target"},
+		{"content and generate", SpecEntry{Path: "x", Content: ptr("a"), Generate: "random"}, "cannot be combined"},
+		{"sized type", SpecEntry{Path: "x", Generate: "png", Size: "1K"}, "natural size"},
+		{"unknown generate", SpecEntry{Path: "x", Generate: "video"}, "invalid generate"},
+		{"invalid size", SpecEntry{Path: "x", Size: "lots"}, "invalid size"},
+		{"invalid mode", SpecEntry{Path: "x", Content: ptr(""), Mode: "999"}, "invalid mode"},
+		{"empty mtime range", SpecEntry{Path: "x", Content: ptr(""), MTime: "2024-01-02..2024-01-01"}, "empty mtime range"},
+		{"hardlink to nothing", SpecEntry{Path: "x", Type: "hardlink", Target: "missing"}, "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildSpec(t.TempDir(), &Spec{Seed: 1, Entries: []SpecEntry{tt.entry}}, 0, "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("BuildSpec() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,419 @@
+package generator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileType is a kind of file the generator can create
+type FileType string
+
+const (
+	TypeText  FileType = "txt"
+	TypePNG   FileType = "png"
+	TypeJPEG  FileType = "jpeg"
+	TypeGIF   FileType = "gif"
+	TypeZip   FileType = "zip"
+	TypeTarGz FileType = "tar.gz"
+	TypeJSON  FileType = "json"
+	TypeCSV   FileType = "csv"
+	TypePDF   FileType = "pdf"
+	TypeMP3   FileType = "mp3"
+)
+
+// typeExts are the extensions of the generated files by type
+var typeExts = map[FileType]string{
+	TypeText:  ".txt",
+	TypePNG:   ".png",
+	TypeJPEG:  ".jpg",
+	TypeGIF:   ".gif",
+	TypeZip:   ".zip",
+	TypeTarGz: ".tar.gz",
+	TypeJSON:  ".json",
+	TypeCSV:   ".csv",
+	TypePDF:   ".pdf",
+	TypeMP3:   ".mp3",
+}
+
+// typeAliases maps other spellings to file types
+var typeAliases = map[string]FileType{
+	"text":  TypeText,
+	"jpg":   TypeJPEG,
+	"tgz":   TypeTarGz,
+	"targz": TypeTarGz,
+}
+
+// TypeWeight is a file type and its share of the generated files
+type TypeWeight struct {
+	Type   FileType
+	Weight int
+}
+
+// ParseTypes parses a weighted type list such as "png:30,txt:50,zip:20". A
+// type without a weight counts 1.
+func ParseTypes(s string) ([]TypeWeight, error) {
+	var types []TypeWeight
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		name, weightText, hasWeight := strings.Cut(item, ":")
+		t := FileType(strings.TrimPrefix(name, "."))
+		if alias, exists := typeAliases[string(t)]; exists {
+			t = alias
+		}
+		if _, known := typeExts[t]; !known {
+			return nil, fmt.Errorf("unknown file type '%s' (expected txt, png, jpeg, gif, zip, tar.gz, json, csv, pdf or mp3)", name)
+		}
+
+		weight := 1
+		if hasWeight {
+			var err error
+			weight, err = strconv.Atoi(weightText)
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight '%s' for %s", weightText, t)
+			}
+		}
+		types = append(types, TypeWeight{Type: t, Weight: weight})
+	}
+
+	total := 0
+	for _, tw := range types {
+		total += tw.Weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("the type list has no weight")
+	}
+	return types, nil
+}
+
+// pickType draws a file type according to the weights; without types every
+// file is text
+func (g *generator) pickType(types []TypeWeight) FileType {
+	total := 0
+	for _, tw := range types {
+		total += tw.Weight
+	}
+	if total == 0 {
+		return TypeText
+	}
+
+	n := g.rng.Intn(total)
+	for _, tw := range types {
+		if n < tw.Weight {
+			return tw.Type
+		}
+		n -= tw.Weight
+	}
+	return TypeText
+}
+
+// typedContent builds a complete file of type t
+func (g *generator) typedContent(t FileType) ([]byte, error) {
+	switch t {
+	case TypePNG, TypeJPEG, TypeGIF:
+		return g.image(t)
+	case TypeZip:
+		return g.zipArchive()
+	case TypeTarGz:
+		return g.tarGzArchive()
+	case TypeJSON:
+		return g.jsonRecords()
+	case TypeCSV:
+		return g.csvRows()
+	case TypePDF:
+		return g.pdf(), nil
+	case TypeMP3:
+		return g.mp3(), nil
+	}
+	return []byte(g.randomContent()), nil
+}
+
+// randomTime returns a time between 2000 and 2024, in whole seconds
+func (g *generator) randomTime() time.Time {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	return start.Add(time.Duration(g.rng.Int63n(25*365*24*3600)) * time.Second)
+}
+
+// image draws a gradient with a few rectangles on it
+func (g *generator) image(t FileType) ([]byte, error) {
+	width, height := 16+g.rng.Intn(241), 16+g.rng.Intn(241)
+	from := color.RGBA{uint8(g.rng.Intn(256)), uint8(g.rng.Intn(256)), uint8(g.rng.Intn(256)), 255}
+	to := color.RGBA{uint8(g.rng.Intn(256)), uint8(g.rng.Intn(256)), uint8(g.rng.Intn(256)), 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		mix := func(a, b uint8) uint8 { return uint8((int(a)*(height-y) + int(b)*y) / height) }
+		c := color.RGBA{mix(from.R, to.R), mix(from.G, to.G), mix(from.B, to.B), 255}
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	for i := g.rng.Intn(5); i > 0; i-- {
+		x, y := g.rng.Intn(width), g.rng.Intn(height)
+		right, bottom := min(width, x+1+g.rng.Intn(width/2)), min(height, y+1+g.rng.Intn(height/2))
+		c := color.RGBA{uint8(g.rng.Intn(256)), uint8(g.rng.Intn(256)), uint8(g.rng.Intn(256)), 255}
+		for ry := y; ry < bottom; ry++ {
+			for rx := x; rx < right; rx++ {
+				img.Set(rx, ry, c)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch t {
+	case TypePNG:
+		err = png.Encode(&buf, img)
+	case TypeGIF:
+		err = gif.Encode(&buf, img, nil)
+	case TypeJPEG:
+		if err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err == nil {
+			return g.withExif(buf.Bytes()), nil
+		}
+	}
+	return buf.Bytes(), err
+}
+
+// cameras are the Make and Model written to generated JPEGs
+var cameras = [][2]string{
+	{"Canon", "Canon EOS 5D Mark IV"},
+	{"NIKON CORPORATION", "NIKON D750"},
+	{"SONY", "ILCE-7M3"},
+	{"Apple", "iPhone 12"},
+	{"FUJIFILM", "X-T3"},
+}
+
+// withExif inserts an APP1 Exif segment with a camera and a capture date
+// after the start of image marker, as a camera would
+func (g *generator) withExif(jpg []byte) []byte {
+	camera := cameras[g.rng.Intn(len(cameras))]
+	taken := g.randomTime().Format("2006:01:02 15:04:05")
+
+	// IFD0 at 8 with 4 entries, the Exif IFD after it with 1, then values
+	const ifd0 = 8
+	const exifIFD = ifd0 + 2 + 4*12 + 4
+	const values = exifIFD + 2 + 12 + 4
+
+	type field struct {
+		tag   uint16
+		value string
+	}
+	ifd0Fields := []field{{0x010F, camera[0]}, {0x0110, camera[1]}, {0x0132, taken}}
+
+	var data bytes.Buffer
+	var tiff bytes.Buffer
+	le := binary.LittleEndian
+	tiff.WriteString("II")
+	binary.Write(&tiff, le, uint16(42))
+	binary.Write(&tiff, le, uint32(ifd0))
+
+	asciiEntry := func(tag uint16, value string) {
+		binary.Write(&tiff, le, tag)
+		binary.Write(&tiff, le, uint16(2))
+		binary.Write(&tiff, le, uint32(len(value)+1))
+		binary.Write(&tiff, le, uint32(values+data.Len()))
+		data.WriteString(value)
+		data.WriteByte(0)
+	}
+
+	binary.Write(&tiff, le, uint16(len(ifd0Fields)+1))
+	for _, f := range ifd0Fields {
+		asciiEntry(f.tag, f.value)
+	}
+	binary.Write(&tiff, le, []uint16{0x8769, 4})
+	binary.Write(&tiff, le, []uint32{1, exifIFD})
+	binary.Write(&tiff, le, uint32(0))
+
+	binary.Write(&tiff, le, uint16(1))
+	asciiEntry(0x9003, taken)
+	binary.Write(&tiff, le, uint32(0))
+	tiff.Write(data.Bytes())
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(jpg[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(jpg[2:])
+	return out.Bytes()
+}
+
+// archiveEntries returns 1 to 5 text files to put in an archive
+func (g *generator) archiveEntries() ([]string, [][]byte, time.Time) {
+	n := 1 + g.rng.Intn(5)
+	names, contents := make([]string, n), make([][]byte, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s_%d.txt", g.randomWords(), i+1)
+		contents[i] = []byte(g.randomContent())
+	}
+	return names, contents, g.randomTime()
+}
+
+func (g *generator) zipArchive() ([]byte, error) {
+	names, contents, modified := g.archiveEntries()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, name := range names {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(contents[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *generator) tarGzArchive() ([]byte, error) {
+	names, contents, modified := g.archiveEntries()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for i, name := range names {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents[i])), ModTime: modified, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(contents[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// record is a row of the generated JSON and CSV files
+type record struct {
+	ID      int     `json:"id"`
+	Name    string  `json:"name"`
+	Score   float64 `json:"score"`
+	Active  bool    `json:"active"`
+	Created string  `json:"created"`
+}
+
+func (g *generator) records() []record {
+	rows := make([]record, 5+g.rng.Intn(46))
+	for i := range rows {
+		rows[i] = record{
+			ID:      i + 1,
+			Name:    g.randomWords(),
+			Score:   float64(g.rng.Intn(10000)) / 100,
+			Active:  g.rng.Intn(2) == 1,
+			Created: g.randomTime().Format(time.RFC3339),
+		}
+	}
+	return rows
+}
+
+func (g *generator) jsonRecords() ([]byte, error) {
+	data, err := json.MarshalIndent(g.records(), "", "  ")
+	return append(data, '\n'), err
+}
+
+func (g *generator) csvRows() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "name", "score", "active", "created"})
+	for _, r := range g.records() {
+		w.Write([]string{strconv.Itoa(r.ID), r.Name, strconv.FormatFloat(r.Score, 'f', 2, 64), strconv.FormatBool(r.Active), r.Created})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// pdf writes a one-page PDF with a line of text and a title, with a correct
+// cross-reference table
+func (g *generator) pdf() []byte {
+	title := g.randomWords()
+	text := sentences[g.rng.Intn(len(sentences))]
+	stream := fmt.Sprintf("BT /F1 18 Tf 72 720 Td (%s) Tj ET", text)
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Title (%s) /Producer (filekit) >>", title),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return buf.Bytes()
+}
+
+// mp3 writes an ID3v2.3 tag followed by silent MPEG-1 Layer III frames
+func (g *generator) mp3() []byte {
+	frames := []struct{ id, value string }{
+		{"TIT2", strings.ReplaceAll(g.randomWords(), "_", " ")},
+		{"TPE1", strings.ReplaceAll(g.randomWords(), "_", " ")},
+		{"TALB", strings.ReplaceAll(g.randomWords(), "_", " ")},
+		{"TRCK", strconv.Itoa(1 + g.rng.Intn(20))},
+		{"TYER", strconv.Itoa(g.randomTime().Year())},
+	}
+
+	var tag bytes.Buffer
+	for _, f := range frames {
+		tag.WriteString(f.id)
+		binary.Write(&tag, binary.BigEndian, uint32(len(f.value)+1))
+		tag.Write([]byte{0, 0, 0}) // flags, ISO-8859-1
+		tag.WriteString(f.value)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("ID3\x03\x00\x00")
+	size := tag.Len()
+	// Syncsafe size: 7 bits per byte
+	buf.Write([]byte{byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)})
+	buf.Write(tag.Bytes())
+
+	// 128 kbit/s, 44.1 kHz, joint stereo: 417 bytes per frame
+	header := []byte{0xFF, 0xFB, 0x90, 0x64}
+	silence := make([]byte, 417-len(header))
+	for i := 10 + g.rng.Intn(30); i > 0; i-- {
+		buf.Write(header)
+		buf.Write(silence)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,141 @@
+package generator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTypes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []TypeWeight
+		wantErr string
+	}{
+		{"png", []TypeWeight{{TypePNG, 1}}, ""},
+		{"png:30, txt:50,zip:20", []TypeWeight{{TypePNG, 30}, {TypeText, 50}, {TypeZip, 20}}, ""},
+		{".JPG,tgz:2,text", []TypeWeight{{TypeJPEG, 1}, {TypeTarGz, 2}, {TypeText, 1}}, ""},
+		{"png:0,txt", []TypeWeight{{TypePNG, 0}, {TypeText, 1}}, ""},
+		{"png,,gif", []TypeWeight{{TypePNG, 1}, {TypeGIF, 1}}, ""},
+		{"docx", nil, "unknown file type 'docx'"},
+		{"png:x", nil, "invalid weight 'x'"},
+		{"png:-1", nil, "invalid weight '-1'"},
+		{"png:0", nil, "no weight"},
+		{"", nil, "no weight"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTypes(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseTypes(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTypes(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+// TestTypedContent checks that every generated type opens with the
+// standard library reader for its format
+func TestTypedContent(t *testing.T) {
+	tests := []struct {
+		fileType FileType
+		check    func([]byte) error
+	}{
+		{TypePNG, decodeImage("png")},
+		{TypeJPEG, decodeImage("jpeg")},
+		{TypeGIF, decodeImage("gif")},
+		{TypeZip, func(data []byte) error {
+			r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				return err
+			}
+			for _, f := range r.File {
+				rc, err := f.Open()
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(io.Discard, rc)
+				rc.Close()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{TypeTarGz, func(data []byte) error {
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+			tr := tar.NewReader(gz)
+			for {
+				if _, err := tr.Next(); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+			}
+		}},
+		{TypeJSON, func(data []byte) error {
+			var v any
+			return json.Unmarshal(data, &v)
+		}},
+		{TypeCSV, func(data []byte) error {
+			_, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			return err
+		}},
+		{TypePDF, prefix("%PDF-")},
+		{TypeMP3, prefix("ID3")},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.fileType), func(t *testing.T) {
+			for seed := int64(1); seed <= 5; seed++ {
+				g := &generator{rng: rand.New(rand.NewSource(seed))}
+				data, err := g.typedContent(tt.fileType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := tt.check(data); err != nil {
+					t.Errorf("seed %d: %v", seed, err)
+				}
+			}
+		})
+	}
+}
+
+func decodeImage(format string) func([]byte) error {
+	return func(data []byte) error {
+		_, got, err := image.Decode(bytes.NewReader(data))
+		if err == nil && got != format {
+			return fmt.Errorf("decoded as %s", got)
+		}
+		return err
+	}
+}
+
+func prefix(p string) func([]byte) error {
+	return func(data []byte) error {
+		if !bytes.HasPrefix(data, []byte(p)) {
+			return fmt.Errorf("does not start with %q", p)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,225 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EXIF tags read by this package
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+	tagImageNumber      = 0x9211
+)
+
+// TIFF field types
+const (
+	typeASCII = 2
+	typeShort = 3
+	typeLong  = 4
+)
+
+// exifTimeLayout is how EXIF writes timestamps
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// readJPEG finds the APP1 Exif segment and parses the TIFF structure in it
+func readJPEG(r io.ReadSeeker, meta *Metadata) error {
+	if _, err := r.Seek(2, io.SeekStart); err != nil {
+		return err
+	}
+
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil
+		}
+		if marker[0] != 0xFF {
+			return fmt.Errorf("invalid JPEG marker")
+		}
+		// Start of scan or end of image: no Exif segment before the image data
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return nil
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil
+		}
+		if length < 2 {
+			return fmt.Errorf("invalid JPEG segment length")
+		}
+
+		if marker[1] != 0xE1 {
+			if _, err := r.Seek(int64(length)-2, io.SeekCurrent); err != nil {
+				return err
+			}
+			continue
+		}
+
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			// APP1 is also used for XMP
+			continue
+		}
+		tiff := segment[6:]
+		return readTIFF(bytes.NewReader(tiff), int64(len(tiff)), meta)
+	}
+}
+
+// tiffReader reads IFD entries from a TIFF structure
+type tiffReader struct {
+	r     io.ReaderAt
+	size  int64
+	order binary.ByteOrder
+}
+
+// ifdEntry is one 12-byte IFD entry
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	raw   [4]byte
+}
+
+// readTIFF parses IFD0 and the Exif sub-IFD
+func readTIFF(r io.ReaderAt, size int64, meta *Metadata) error {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("invalid TIFF header: %v", err)
+	}
+
+	t := &tiffReader{r: r, size: size}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return fmt.Errorf("invalid TIFF byte order")
+	}
+
+	ifd0, err := t.readIFD(int64(t.order.Uint32(header[4:])))
+	if err != nil {
+		return err
+	}
+
+	var dateTime string
+	var exifOffset int64
+	for _, entry := range ifd0 {
+		switch entry.tag {
+		case tagMake:
+			meta.Make = t.ascii(entry)
+		case tagModel:
+			meta.Model = t.ascii(entry)
+		case tagDateTime:
+			dateTime = t.ascii(entry)
+		case tagExifIFD:
+			exifOffset = int64(t.integer(entry))
+		}
+	}
+
+	if exifOffset > 0 {
+		exifIFD, err := t.readIFD(exifOffset)
+		if err != nil {
+			return err
+		}
+		for _, entry := range exifIFD {
+			switch entry.tag {
+			case tagDateTimeOriginal:
+				if value := t.ascii(entry); value != "" {
+					dateTime = value
+				}
+			case tagImageNumber:
+				meta.ImageNumber = strconv.FormatUint(uint64(t.integer(entry)), 10)
+			}
+		}
+	}
+
+	// Cameras without a clock write zeros or blanks
+	if parsed, err := time.Parse(exifTimeLayout, dateTime); err == nil {
+		meta.DateTimeOriginal = parsed
+	}
+
+	return nil
+}
+
+// readIFD reads the entries of the IFD at offset
+func (t *tiffReader) readIFD(offset int64) ([]ifdEntry, error) {
+	var countBytes [2]byte
+	if offset <= 0 || offset+2 > t.size {
+		return nil, fmt.Errorf("invalid IFD offset %d", offset)
+	}
+	if _, err := t.r.ReadAt(countBytes[:], offset); err != nil {
+		return nil, err
+	}
+
+	count := int64(t.order.Uint16(countBytes[:]))
+	if offset+2+count*12 > t.size {
+		return nil, fmt.Errorf("truncated IFD at offset %d", offset)
+	}
+
+	data := make([]byte, count*12)
+	if _, err := t.r.ReadAt(data, offset+2); err != nil {
+		return nil, err
+	}
+
+	entries := make([]ifdEntry, count)
+	for i := range entries {
+		b := data[i*12 : i*12+12]
+		entries[i] = ifdEntry{
+			tag:   t.order.Uint16(b[0:]),
+			typ:   t.order.Uint16(b[2:]),
+			count: t.order.Uint32(b[4:]),
+		}
+		copy(entries[i].raw[:], b[8:12])
+	}
+	return entries, nil
+}
+
+// ascii returns an ASCII value, stored inline when it fits in four bytes
+func (t *tiffReader) ascii(entry ifdEntry) string {
+	if entry.typ != typeASCII || entry.count == 0 {
+		return ""
+	}
+
+	var data []byte
+	if entry.count <= 4 {
+		data = entry.raw[:entry.count]
+	} else {
+		offset := int64(t.order.Uint32(entry.raw[:]))
+		if offset+int64(entry.count) > t.size {
+			return ""
+		}
+		data = make([]byte, entry.count)
+		if _, err := t.r.ReadAt(data, offset); err != nil {
+			return ""
+		}
+	}
+
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// integer returns a SHORT or LONG value
+func (t *tiffReader) integer(entry ifdEntry) uint32 {
+	switch entry.typ {
+	case typeShort:
+		return uint32(t.order.Uint16(entry.raw[:]))
+	case typeLong:
+		return t.order.Uint32(entry.raw[:])
+	}
+	return 0
+}
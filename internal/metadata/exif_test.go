@@ -0,0 +1,163 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tiffField is an IFD entry for buildTIFF; text becomes an ASCII value and
+// is stored after the IFDs when it does not fit inline
+type tiffField struct {
+	tag   uint16
+	text  string
+	value uint32
+}
+
+// buildTIFF lays out a TIFF header, IFD0 with a pointer to the Exif IFD
+// when exif has entries, the Exif IFD and the out-of-line ASCII values
+func buildTIFF(order binary.ByteOrder, ifd0, exif []tiffField) []byte {
+	if len(exif) > 0 {
+		ifd0 = append(ifd0, tiffField{tag: tagExifIFD})
+	}
+	ifdSize := func(fields []tiffField) int { return 2 + 12*len(fields) + 4 }
+	exifOffset := 8 + ifdSize(ifd0)
+	dataOffset := exifOffset
+	if len(exif) > 0 {
+		dataOffset += ifdSize(exif)
+	}
+
+	var ifds, data bytes.Buffer
+	writeIFD := func(fields []tiffField) {
+		binary.Write(&ifds, order, uint16(len(fields)))
+		for _, f := range fields {
+			binary.Write(&ifds, order, f.tag)
+			var raw [4]byte
+			switch {
+			case f.tag == tagExifIFD:
+				binary.Write(&ifds, order, uint16(typeLong))
+				binary.Write(&ifds, order, uint32(1))
+				order.PutUint32(raw[:], uint32(exifOffset))
+			case f.text != "":
+				value := f.text + "\x00"
+				binary.Write(&ifds, order, uint16(typeASCII))
+				binary.Write(&ifds, order, uint32(len(value)))
+				if len(value) <= 4 {
+					copy(raw[:], value)
+				} else {
+					order.PutUint32(raw[:], uint32(dataOffset+data.Len()))
+					data.WriteString(value)
+				}
+			default:
+				binary.Write(&ifds, order, uint16(typeLong))
+				binary.Write(&ifds, order, uint32(1))
+				order.PutUint32(raw[:], f.value)
+			}
+			ifds.Write(raw[:])
+		}
+		ifds.Write([]byte{0, 0, 0, 0})
+	}
+	writeIFD(ifd0)
+	if len(exif) > 0 {
+		writeIFD(exif)
+	}
+
+	header := []byte("II*\x00")
+	if order == binary.BigEndian {
+		header = []byte("MM\x00*")
+	}
+	header = append(header, 0, 0, 0, 0)
+	order.PutUint32(header[4:], 8)
+	return append(append(header, ifds.Bytes()...), data.Bytes()...)
+}
+
+// buildJPEG wraps a TIFF structure in an APP1 Exif segment, after an APP0
+// segment the reader has to skip
+func buildJPEG(tiff []byte) []byte {
+	b := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x06, 'J', 'F', 'I', 'F'}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	b = append(b, 0xFF, 0xE1)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(segment)+2))
+	b = append(b, segment...)
+	return append(b, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func TestReadJPEG(t *testing.T) {
+	camera := []tiffField{{tag: tagMake, text: "Canon"}, {tag: tagModel, text: "X1"}, {tag: tagDateTime, text: "2020:01:01 00:00:00"}}
+	shot := []tiffField{{tag: tagDateTimeOriginal, text: "2023:12:31 10:30:45"}, {tag: tagImageNumber, value: 1234}}
+	taken := time.Date(2023, 12, 31, 10, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Metadata
+		wantErr string
+	}{
+		{
+			name: "little-endian",
+			data: buildJPEG(buildTIFF(binary.LittleEndian, camera, shot)),
+			want: Metadata{Make: "Canon", Model: "X1", DateTimeOriginal: taken, ImageNumber: "1234"},
+		},
+		{
+			name: "big-endian",
+			data: buildJPEG(buildTIFF(binary.BigEndian, camera, shot)),
+			want: Metadata{Make: "Canon", Model: "X1", DateTimeOriginal: taken, ImageNumber: "1234"},
+		},
+		{
+			name: "no Exif IFD falls back to DateTime",
+			data: buildJPEG(buildTIFF(binary.LittleEndian, camera, nil)),
+			want: Metadata{Make: "Canon", Model: "X1", DateTimeOriginal: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "blank date",
+			data: buildJPEG(buildTIFF(binary.LittleEndian, nil, []tiffField{{tag: tagDateTimeOriginal, text: "    :  :     :  :  "}})),
+			want: Metadata{},
+		},
+		{
+			name: "no Exif segment",
+			data: []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9},
+			want: Metadata{},
+		},
+		{
+			name:    "corrupt IFD0 offset",
+			data:    buildJPEG(append([]byte("II*\x00\xff\xff\x00\x00"), make([]byte, 8)...)),
+			wantErr: "invalid IFD offset 65535",
+		},
+		{
+			name: "corrupt Exif IFD offset",
+			data: func() []byte {
+				tiff := buildTIFF(binary.LittleEndian, nil, shot)
+				// The pointer is the only IFD0 entry; its value starts at 8+2+8
+				binary.LittleEndian.PutUint32(tiff[18:], 0x7FFFFFFF)
+				return buildJPEG(tiff)
+			}(),
+			wantErr: "invalid IFD offset",
+		},
+		{
+			name:    "truncated IFD",
+			data:    buildJPEG(append([]byte("II*\x00\x08\x00\x00\x00"), 0xFF, 0x00)),
+			wantErr: "truncated IFD at offset 8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta Metadata
+			err := readJPEG(bytes.NewReader(tt.data), &meta)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta != tt.want {
+				t.Errorf("metadata = %+v, want %+v", meta, tt.want)
+			}
+		})
+	}
+}
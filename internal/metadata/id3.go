@@ -0,0 +1,228 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// readID3v2 parses the ID3v2.2, v2.3 or v2.4 tag at the start of r
+func readID3v2(r io.ReadSeeker, meta *Metadata) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	if version < 2 || version > 4 {
+		return fmt.Errorf("unsupported ID3v2 version 2.%d", version)
+	}
+
+	// The size comes from the file; check it against what is there before
+	// allocating it
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if int64(size) > end-int64(len(header)) {
+		return fmt.Errorf("truncated ID3v2 tag")
+	}
+	if _, err := r.Seek(int64(len(header)), io.SeekStart); err != nil {
+		return err
+	}
+
+	tag := make([]byte, size)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return fmt.Errorf("truncated ID3v2 tag")
+	}
+
+	// Whole-tag unsynchronisation (v2.2 and v2.3; v2.4 marks it per frame)
+	if flags&0x80 != 0 && version < 4 {
+		tag = bytes.ReplaceAll(tag, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+
+	// Skip the extended header
+	if flags&0x40 != 0 && version >= 3 && len(tag) >= 4 {
+		extSize := int(binary.BigEndian.Uint32(tag[:4])) + 4
+		if version == 4 {
+			extSize = syncsafe(tag[:4])
+		}
+		if extSize > len(tag) {
+			return fmt.Errorf("invalid ID3v2 extended header")
+		}
+		tag = tag[extSize:]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	for len(tag) >= headerLen && tag[0] != 0 {
+		id := string(tag[:idLen])
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(tag[4:8]))
+		default:
+			frameSize = syncsafe(tag[4:8])
+		}
+		if frameSize <= 0 || headerLen+frameSize > len(tag) {
+			break
+		}
+
+		frame := tag[headerLen : headerLen+frameSize]
+		if version == 4 && tag[9]&0x02 != 0 {
+			frame = bytes.ReplaceAll(frame, []byte{0xFF, 0x00}, []byte{0xFF})
+		}
+		tag = tag[headerLen+frameSize:]
+
+		if !strings.HasPrefix(id, "T") {
+			continue
+		}
+		value := decodeID3Text(frame)
+		switch id {
+		case "TPE1", "TP1":
+			meta.Artist = value
+		case "TPE2", "TP2":
+			meta.AlbumArtist = value
+		case "TALB", "TAL":
+			meta.Album = value
+		case "TIT2", "TT2":
+			meta.Title = value
+		case "TRCK", "TRK":
+			meta.Track = trackNumber(value)
+		case "TYER", "TYE", "TDRC":
+			meta.Year = yearOf(value)
+		}
+	}
+
+	return nil
+}
+
+// readID3v1 parses the 128-byte ID3v1 tag at the end of the file, filling
+// only fields that are still empty
+func readID3v1(r io.ReadSeeker, size int64, meta *Metadata) error {
+	if size < 128 {
+		return nil
+	}
+	if _, err := r.Seek(size-128, io.SeekStart); err != nil {
+		return err
+	}
+
+	tag := make([]byte, 128)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return err
+	}
+	if string(tag[:3]) != "TAG" {
+		return nil
+	}
+
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return strings.TrimSpace(latin1(b))
+	}
+
+	setIfEmpty(&meta.Title, field(tag[3:33]))
+	setIfEmpty(&meta.Artist, field(tag[33:63]))
+	setIfEmpty(&meta.Album, field(tag[63:93]))
+	setIfEmpty(&meta.Year, field(tag[93:97]))
+	// ID3v1.1 keeps the track number in the last byte of the comment
+	if tag[125] == 0 && tag[126] != 0 {
+		setIfEmpty(&meta.Track, fmt.Sprintf("%d", tag[126]))
+	}
+
+	return nil
+}
+
+// decodeID3Text decodes a text frame, returning its first value
+func decodeID3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+
+	encoding, data := frame[0], frame[1:]
+	var text string
+	switch encoding {
+	case 0:
+		text = latin1(data)
+	case 1, 2:
+		text = decodeUTF16(data, encoding == 2)
+	default:
+		text = string(data)
+	}
+
+	// Multiple values are separated by NUL
+	if i := strings.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// decodeUTF16 decodes UTF-16 text, honouring a byte order mark
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			data, bigEndian = data[2:], false
+		case data[0] == 0xFE && data[1] == 0xFF:
+			data, bigEndian = data[2:], true
+		}
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// latin1 decodes ISO-8859-1 bytes
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// syncsafe decodes a 28-bit integer stored in four 7-bit bytes
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// trackNumber drops the total from values like "3/12"
+func trackNumber(value string) string {
+	track, _, _ := strings.Cut(value, "/")
+	return strings.TrimSpace(track)
+}
+
+// yearOf keeps the year of a date such as "2024-05-03"
+func yearOf(value string) string {
+	if len(value) >= 4 {
+		return value[:4]
+	}
+	return value
+}
+
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
@@ -0,0 +1,146 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// id3Frame builds a text frame in the layout of the given ID3v2 version
+func id3Frame(version byte, id string, encoding byte, text []byte) []byte {
+	data := append([]byte{encoding}, text...)
+	var b bytes.Buffer
+	b.WriteString(id)
+	switch version {
+	case 2:
+		b.Write([]byte{byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))})
+	case 3:
+		binary.Write(&b, binary.BigEndian, uint32(len(data)))
+		b.Write([]byte{0, 0})
+	default:
+		b.Write(syncsafeBytes(len(data)))
+		b.Write([]byte{0, 0})
+	}
+	b.Write(data)
+	return b.Bytes()
+}
+
+// id3Tag wraps frames in an ID3v2 header declaring size bytes; a negative
+// size declares the length of the frames
+func id3Tag(version byte, size int, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	if size < 0 {
+		size = len(body)
+	}
+	tag := append([]byte{'I', 'D', '3', version, 0, 0}, syncsafeBytes(size)...)
+	return append(tag, body...)
+}
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n>>21) & 0x7F, byte(n>>14) & 0x7F, byte(n>>7) & 0x7F, byte(n) & 0x7F}
+}
+
+// utf16LE encodes ASCII text as UTF-16 with a little-endian byte order mark
+func utf16LE(s string) []byte {
+	b := []byte{0xFF, 0xFE}
+	for _, r := range s {
+		b = append(b, byte(r), 0)
+	}
+	return b
+}
+
+func TestReadID3v2(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Metadata
+		wantErr string
+	}{
+		{
+			name: "v2.2",
+			data: id3Tag(2, -1,
+				id3Frame(2, "TT2", 0, []byte("Song")),
+				id3Frame(2, "TP1", 0, []byte("Band")),
+				id3Frame(2, "TRK", 0, []byte("3/12")),
+				id3Frame(2, "TYE", 0, []byte("1999"))),
+			want: Metadata{Title: "Song", Artist: "Band", Track: "3", Year: "1999"},
+		},
+		{
+			name: "v2.3",
+			data: id3Tag(3, -1,
+				id3Frame(3, "TIT2", 1, utf16LE("Song")),
+				id3Frame(3, "TPE1", 0, []byte("Caf\xe9")),
+				id3Frame(3, "TPE2", 0, []byte("Various")),
+				id3Frame(3, "TALB", 0, []byte("Album\x00")),
+				id3Frame(3, "TRCK", 0, []byte("3/12"))),
+			want: Metadata{Title: "Song", Artist: "Café", AlbumArtist: "Various", Album: "Album", Track: "3"},
+		},
+		{
+			name: "v2.4",
+			data: id3Tag(4, -1,
+				id3Frame(4, "TIT2", 3, []byte("Sång")),
+				id3Frame(4, "TRCK", 3, []byte("3/12")),
+				id3Frame(4, "TDRC", 3, []byte("2024-05-03"))),
+			want: Metadata{Title: "Sång", Track: "3", Year: "2024"},
+		},
+		{
+			name: "padding after the frames",
+			data: append(id3Tag(3, 64, id3Frame(3, "TRCK", 0, []byte("3/12"))), make([]byte, 64)...),
+			want: Metadata{Track: "3"},
+		},
+		{
+			name:    "oversized tag",
+			data:    id3Tag(3, 0x0FFFFFFF, id3Frame(3, "TRCK", 0, []byte("3/12"))),
+			wantErr: "truncated ID3v2 tag",
+		},
+		{
+			name:    "truncated tag",
+			data:    id3Tag(4, 100, id3Frame(4, "TRCK", 0, []byte("3/12"))),
+			wantErr: "truncated ID3v2 tag",
+		},
+		{
+			name:    "unknown version",
+			data:    id3Tag(5, -1),
+			wantErr: "unsupported ID3v2 version 2.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta Metadata
+			err := readID3v2(bytes.NewReader(tt.data), &meta)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta != tt.want {
+				t.Errorf("metadata = %+v, want %+v", meta, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadID3v1(t *testing.T) {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:], "Song")
+	copy(tag[33:], "Band")
+	copy(tag[93:], "1999")
+	tag[126] = 7
+	data := append(make([]byte, 256), tag...)
+
+	meta := Metadata{Title: "From ID3v2"}
+	if err := readID3v1(bytes.NewReader(data), int64(len(data)), &meta); err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{Title: "From ID3v2", Artist: "Band", Year: "1999", Track: "7"}
+	if meta != want {
+		t.Errorf("metadata = %+v, want %+v", meta, want)
+	}
+}
@@ -0,0 +1,89 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrUnsupported is returned for files whose format carries no metadata
+// this package understands
+var ErrUnsupported = errors.New("unsupported file format")
+
+// Metadata holds the embedded fields used for renaming. Fields that are not
+// present in the file are left empty.
+type Metadata struct {
+	// Photo fields from EXIF
+	DateTimeOriginal time.Time
+	Make             string
+	Model            string
+	// ImageNumber is the camera's shot counter, when it records one
+	ImageNumber string
+
+	// Audio fields from ID3 or Vorbis comments
+	Artist      string
+	AlbumArtist string
+	Album       string
+	Title       string
+	// Track is the track number without the total, e.g. "3" for "3/12"
+	Track string
+	Year  string
+}
+
+// Read detects the format of the file at path and parses its metadata.
+// JPEG and TIFF (including TIFF-based raw formats) are read for EXIF, MP3
+// for ID3v2/ID3v1, and FLAC, Ogg Vorbis and Opus for Vorbis comments.
+func Read(path string) (*Metadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	header = header[:n]
+
+	meta := &Metadata{}
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8}):
+		err = readJPEG(file, meta)
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		err = readTIFF(file, info.Size(), meta)
+	case bytes.HasPrefix(header, []byte("ID3")):
+		err = readID3v2(file, meta)
+		if err == nil && meta.Title == "" && meta.Artist == "" {
+			err = readID3v1(file, info.Size(), meta)
+		}
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		err = readFLAC(file, meta)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		err = readOgg(file, meta)
+	case isMPEGFrame(header):
+		err = readID3v1(file, info.Size(), meta)
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata from %s: %v", path, err)
+	}
+
+	return meta, nil
+}
+
+// isMPEGFrame reports whether header starts with an MPEG audio frame sync,
+// as MP3 files without an ID3v2 tag do
+func isMPEGFrame(header []byte) bool {
+	return len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0
+}
@@ -0,0 +1,174 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxOggPages bounds how far into an Ogg stream the comment header is
+// looked for; it always sits in the first few pages
+const maxOggPages = 64
+
+// readFLAC walks the FLAC metadata blocks looking for VORBIS_COMMENT
+func readFLAC(r io.ReadSeeker, meta *Metadata) error {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		if blockType == 4 {
+			block := make([]byte, length)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return fmt.Errorf("truncated FLAC comment block")
+			}
+			return parseVorbisComment(block, meta)
+		}
+
+		if last {
+			return nil
+		}
+		if _, err := r.Seek(length, io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+}
+
+// readOgg reassembles the second packet of the first logical stream, which
+// holds the comment header for both Vorbis and Opus
+func readOgg(r io.ReadSeeker, meta *Metadata) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var packet []byte
+	packets := 0
+	var serial uint32
+
+	for page := 0; page < maxOggPages; page++ {
+		header := make([]byte, 27)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil
+		}
+		if string(header[:4]) != "OggS" {
+			return fmt.Errorf("invalid Ogg page")
+		}
+		pageSerial := binary.LittleEndian.Uint32(header[14:18])
+		if page == 0 {
+			serial = pageSerial
+		}
+
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return err
+		}
+		bodySize := 0
+		for _, s := range segments {
+			bodySize += int(s)
+		}
+		body := make([]byte, bodySize)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return err
+		}
+		if pageSerial != serial {
+			continue
+		}
+
+		// A lacing value below 255 ends a packet
+		offset := 0
+		for _, s := range segments {
+			packet = append(packet, body[offset:offset+int(s)]...)
+			offset += int(s)
+			if s == 255 {
+				continue
+			}
+
+			packets++
+			if packets == 2 {
+				return parseOggComment(packet, meta)
+			}
+			packet = packet[:0]
+		}
+	}
+
+	return nil
+}
+
+// parseOggComment strips the codec specific prefix of a comment packet
+func parseOggComment(packet []byte, meta *Metadata) error {
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+		return parseVorbisComment(packet[7:], meta)
+	case bytes.HasPrefix(packet, []byte("OpusTags")):
+		return parseVorbisComment(packet[8:], meta)
+	}
+	return nil
+}
+
+// parseVorbisComment reads the vendor string and KEY=value comments
+func parseVorbisComment(data []byte, meta *Metadata) error {
+	next := func() ([]byte, error) {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated Vorbis comment")
+		}
+		n := binary.LittleEndian.Uint32(data)
+		data = data[4:]
+		if uint64(n) > uint64(len(data)) {
+			return nil, fmt.Errorf("truncated Vorbis comment")
+		}
+		value := data[:n]
+		data = data[n:]
+		return value, nil
+	}
+
+	// Vendor string
+	if _, err := next(); err != nil {
+		return err
+	}
+
+	if len(data) < 4 {
+		return fmt.Errorf("truncated Vorbis comment")
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	for i := uint32(0); i < count; i++ {
+		comment, err := next()
+		if err != nil {
+			return err
+		}
+		key, value, found := strings.Cut(string(comment), "=")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		// The first value wins when a field is repeated
+		switch strings.ToUpper(key) {
+		case "ARTIST":
+			setIfEmpty(&meta.Artist, value)
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			setIfEmpty(&meta.AlbumArtist, value)
+		case "ALBUM":
+			setIfEmpty(&meta.Album, value)
+		case "TITLE":
+			setIfEmpty(&meta.Title, value)
+		case "TRACKNUMBER":
+			setIfEmpty(&meta.Track, trackNumber(value))
+		case "DATE", "YEAR":
+			setIfEmpty(&meta.Year, yearOf(value))
+		}
+	}
+
+	return nil
+}
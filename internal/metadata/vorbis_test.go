@@ -0,0 +1,137 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+// vorbisComment builds a comment block with a vendor string
+func vorbisComment(comments ...string) []byte {
+	vendor := "filekit"
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+	b = append(b, vendor...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(comments)))
+	for _, c := range comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(c)))
+		b = append(b, c...)
+	}
+	return b
+}
+
+// flacFile builds a FLAC stream with an empty STREAMINFO block followed by
+// a VORBIS_COMMENT block
+func flacFile(comment []byte) []byte {
+	b := []byte("fLaC")
+	b = append(b, 0, 0, 0, 34)
+	b = append(b, make([]byte, 34)...)
+	b = append(b, 0x80|4, byte(len(comment)>>16), byte(len(comment)>>8), byte(len(comment)))
+	return append(b, comment...)
+}
+
+// oggPage builds one Ogg page holding the given packets, all complete
+func oggPage(serial uint32, packets ...[]byte) []byte {
+	var lacing, body []byte
+	for _, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			lacing = append(lacing, 255)
+		}
+		lacing = append(lacing, byte(n))
+		body = append(body, p...)
+	}
+
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	binary.LittleEndian.PutUint32(header[14:], serial)
+	header[26] = byte(len(lacing))
+	return append(append(header, lacing...), body...)
+}
+
+func TestReadVorbisComments(t *testing.T) {
+	comment := vorbisComment("ARTIST=Band", "artist=Other", "TITLE=Song", "ALBUM ARTIST=Various", "TRACKNUMBER=3/12", "DATE=2024-05-03", "NOEQUALS")
+	want := Metadata{Artist: "Band", Title: "Song", AlbumArtist: "Various", Track: "3", Year: "2024"}
+	long := vorbisComment("TITLE=" + strings.Repeat("x", 600))
+
+	tests := []struct {
+		name    string
+		read    func(io.ReadSeeker, *Metadata) error
+		data    []byte
+		want    Metadata
+		wantErr string
+	}{
+		{
+			name: "FLAC",
+			read: readFLAC,
+			data: flacFile(comment),
+			want: want,
+		},
+		{
+			name:    "truncated FLAC comment",
+			read:    readFLAC,
+			data:    flacFile(comment)[:60],
+			wantErr: "truncated FLAC comment block",
+		},
+		{
+			name:    "FLAC comment with a bad count",
+			read:    readFLAC,
+			data:    flacFile(vorbisComment("TITLE=Song")[:14]),
+			wantErr: "truncated Vorbis comment",
+		},
+		{
+			name: "Ogg Vorbis",
+			read: readOgg,
+			data: append(oggPage(7, []byte("\x01vorbis-id")), oggPage(7, append([]byte("\x03vorbis"), comment...))...),
+			want: want,
+		},
+		{
+			name: "Opus with both headers on one page",
+			read: readOgg,
+			data: oggPage(7, []byte("OpusHead"), append([]byte("OpusTags"), comment...)),
+			want: want,
+		},
+		{
+			name: "Ogg comment over 255 bytes",
+			read: readOgg,
+			data: append(oggPage(7, []byte("\x01vorbis-id")), oggPage(7, append([]byte("\x03vorbis"), long...))...),
+			want: Metadata{Title: strings.Repeat("x", 600)},
+		},
+		{
+			name: "pages of another stream are skipped",
+			read: readOgg,
+			data: bytes.Join([][]byte{
+				oggPage(7, []byte("\x01vorbis-id")),
+				oggPage(9, append([]byte("\x03vorbis"), vorbisComment("TITLE=Wrong")...)),
+				oggPage(7, append([]byte("\x03vorbis"), comment...)),
+			}, nil),
+			want: want,
+		},
+		{
+			name:    "not an Ogg page",
+			read:    readOgg,
+			data:    append(oggPage(7, []byte("\x01vorbis-id")), []byte("junk that is not a page header")...),
+			wantErr: "invalid Ogg page",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta Metadata
+			err := tt.read(bytes.NewReader(tt.data), &meta)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta != tt.want {
+				t.Errorf("metadata = %+v, want %+v", meta, tt.want)
+			}
+		})
+	}
+}
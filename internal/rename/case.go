@@ -0,0 +1,225 @@
+package rename
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// CaseMode is a case conversion or Unicode normalization applied to names
+type CaseMode string
+
+const (
+	CaseLower CaseMode = "lower"
+	CaseUpper CaseMode = "upper"
+	CaseTitle CaseMode = "title"
+	CaseSnake CaseMode = "snake"
+	CaseKebab CaseMode = "kebab"
+	CaseCamel CaseMode = "camel"
+	// NormNFC composes characters, the form Linux and Windows tools expect
+	NormNFC CaseMode = "nfc"
+	// NormNFD decomposes characters, the form macOS clients often produce
+	NormNFD CaseMode = "nfd"
+)
+
+// ParseCaseMode converts a flag value such as "snake", "snake_case" or
+// "camelCase" into a CaseMode
+func ParseCaseMode(s string) (CaseMode, error) {
+	mode := strings.ToLower(s)
+	for _, suffix := range []string{"_case", "-case", "case"} {
+		if trimmed := strings.TrimSuffix(mode, suffix); trimmed != "" {
+			mode = trimmed
+		}
+	}
+
+	switch m := CaseMode(mode); m {
+	case CaseLower, CaseUpper, CaseTitle, CaseSnake, CaseKebab, CaseCamel, NormNFC, NormNFD:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid case mode '%s' (expected lower, upper, title, snake, kebab, camel, nfc or nfd)", s)
+}
+
+// CaseOptions controls ChangeCase
+type CaseOptions struct {
+	// Part selects the part of the name to convert; the zero value means
+	// the full name
+	Part NamePart
+	// Scope selects which files and directories are visited
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// ChangeCase renames entries under dir by converting their names with mode.
+// Case-only changes are safe on case-insensitive filesystems because they
+// go through a temporary name. Names that are not valid UTF-8 are reported
+// and left alone.
+func ChangeCase(dir string, mode CaseMode, opts CaseOptions) (int, error) {
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+	entries = skipInvalidUTF8(entries)
+
+	plan, err := PlanNameChanges(dir, entries, opts.Part, func(name string) (string, bool) {
+		return ConvertCase(name, mode), true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// ConvertCase returns s converted with mode. A string that is not valid
+// UTF-8 is returned unchanged, since converting it would replace the
+// invalid bytes that fix-encoding needs to recover the name.
+func ConvertCase(s string, mode CaseMode) string {
+	if !utf8.ValidString(s) {
+		return s
+	}
+
+	switch mode {
+	case NormNFC:
+		return norm.NFC.String(s)
+	case NormNFD:
+		return norm.NFD.String(s)
+	}
+
+	// Word based conversions work on composed text so that an accent is
+	// never split from its letter; decomposed names are decomposed again
+	// afterwards so only the case changes
+	decomposed := !norm.NFC.IsNormalString(s)
+	s = norm.NFC.String(s)
+
+	switch mode {
+	case CaseLower:
+		s = strings.ToLower(s)
+	case CaseUpper:
+		s = strings.ToUpper(s)
+	case CaseTitle:
+		s = titleCase(s)
+	case CaseSnake:
+		s = joinWords(splitWords(s), "_")
+	case CaseKebab:
+		s = joinWords(splitWords(s), "-")
+	case CaseCamel:
+		words := splitWords(s)
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 {
+				word = capitalize(word)
+			}
+			words[i] = word
+		}
+		s = strings.Join(words, "")
+	}
+
+	if decomposed {
+		return norm.NFD.String(s)
+	}
+	return s
+}
+
+// skipInvalidUTF8 drops and reports the entries whose names are not valid
+// UTF-8
+func skipInvalidUTF8(entries []Entry) []Entry {
+	var kept []Entry
+	var skipped []string
+	for _, entry := range entries {
+		if utf8.ValidString(entry.Info.Name()) {
+			kept = append(kept, entry)
+			continue
+		}
+		skipped = append(skipped, fmt.Sprintf("  %q", entry.RelPath))
+	}
+
+	if len(skipped) > 0 {
+		fmt.Printf("Skipped %d name(s) that are not valid UTF-8, left unchanged (fix them with fix-encoding first):\n%s\n", len(skipped), strings.Join(skipped, "\n"))
+	}
+	return kept
+}
+
+// isWordRune reports whether r belongs to a word rather than a separator
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '\''
+}
+
+// splitWords breaks s into words at separators and at case changes, so
+// "HTTPServer log-file" becomes "HTTP", "Server", "log", "file"
+func splitWords(s string) []string {
+	var words []string
+	var current []rune
+
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = current[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if r == '\'' {
+			// "don't" is one word
+			continue
+		}
+		if !isWordRune(r) {
+			flush()
+			continue
+		}
+
+		if len(current) > 0 && unicode.IsUpper(r) {
+			prev := current[len(current)-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// fooBar, file2Name, and the end of an acronym as in HTTPServer
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+
+	return words
+}
+
+// joinWords lower-cases words and joins them with sep
+func joinWords(words []string, sep string) string {
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, sep)
+}
+
+// titleCase upper-cases the first letter of every word and lower-cases the
+// rest, keeping separators as they are
+func titleCase(s string) string {
+	var b strings.Builder
+	inWord := false
+	for _, r := range s {
+		switch {
+		case !isWordRune(r):
+			inWord = false
+		case !inWord:
+			r = unicode.ToTitle(r)
+			inWord = true
+		default:
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// capitalize upper-cases the first rune of s
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToTitle(r)) + s[size:]
+}
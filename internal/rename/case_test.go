@@ -0,0 +1,57 @@
+package rename
+
+import (
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestConvertCase(t *testing.T) {
+	tests := []struct {
+		in   string
+		mode CaseMode
+		want string
+	}{
+		{"Hello World", CaseLower, "hello world"},
+		{"Hello World", CaseUpper, "HELLO WORLD"},
+		{"hello world", CaseTitle, "Hello World"},
+		{"HTTPServer log-file", CaseSnake, "http_server_log_file"},
+		{"HTTPServer log-file", CaseKebab, "http-server-log-file"},
+		{"http server log", CaseCamel, "httpServerLog"},
+		{"Café", CaseUpper, "CAFÉ"},
+		{norm.NFD.String("Café"), CaseUpper, norm.NFD.String("CAFÉ")},
+		{norm.NFD.String("café"), NormNFC, "café"},
+
+		// Names that are not UTF-8 are left alone in every mode
+		{"CAF\xe9.TXT", CaseLower, "CAF\xe9.TXT"},
+		{"caf\xe9.txt", CaseUpper, "caf\xe9.txt"},
+		{"caf\xe9 name", CaseTitle, "caf\xe9 name"},
+		{"caf\xe9 name", CaseSnake, "caf\xe9 name"},
+		{"caf\xe9 name", CaseKebab, "caf\xe9 name"},
+		{"caf\xe9 name", CaseCamel, "caf\xe9 name"},
+		{"caf\xe9", NormNFC, "caf\xe9"},
+		{"caf\xe9", NormNFD, "caf\xe9"},
+	}
+
+	for _, tt := range tests {
+		if got := ConvertCase(tt.in, tt.mode); got != tt.want {
+			t.Errorf("ConvertCase(%q, %s) = %q, want %q", tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestPipelineKeepsInvalidUTF8(t *testing.T) {
+	root := makeTree(t, map[string]string{"caf\xe9.txt": "x", "Hello.txt": "y"})
+
+	steps := []Step{{Op: "case", Mode: "lower"}, {Op: "prefix", Text: "a_"}}
+	if _, err := RunPipeline(root, steps, PipelineOptions{Part: PartFull}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readTree(t, root)
+	for _, name := range []string{"caf\xe9.txt", "a_hello.txt"} {
+		if _, exists := got[name]; !exists {
+			t.Errorf("%q missing, tree is %q", name, got)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package rename
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DateOrder is the field order used for numeric dates that could be read
+// more than one way, such as 01-02-2023
+type DateOrder string
+
+const (
+	OrderDMY DateOrder = "dmy"
+	OrderMDY DateOrder = "mdy"
+	OrderYMD DateOrder = "ymd"
+)
+
+// ParseDateOrder converts a flag value into a DateOrder; an empty value
+// means ambiguous dates are reported instead of converted
+func ParseDateOrder(s string) (DateOrder, error) {
+	switch o := DateOrder(strings.ToLower(s)); o {
+	case "", OrderDMY, OrderMDY, OrderYMD:
+		return o, nil
+	}
+	return "", fmt.Errorf("invalid date order '%s' (expected dmy, mdy or ymd)", s)
+}
+
+// DateOptions controls NormalizeDates
+type DateOptions struct {
+	// Order resolves ambiguous numeric dates; when empty they are reported
+	Order DateOrder
+	// Scope selects which files and directories are visited
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// isoDateLayout is what every recognized date is rewritten to
+const isoDateLayout = "2006-01-02"
+
+const monthPattern = `(jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sept?(?:ember)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)`
+
+// The date patterns, tried at every position where a date may start
+var (
+	// 2023-12-31, 12-31-2023, 31.12.23, 1_2_2023
+	numericDateRe = regexp.MustCompile(`^(\d{1,4})([-._ ])(\d{1,2})([-._ ])(\d{1,4})`)
+	// 20231231, 31122023
+	compactDateRe = regexp.MustCompile(`^\d{8}`)
+	// Dec 31 2023, December 31st, 2023, dec-31-23; day and year are kept
+	// apart so that May 2023 is not read as 20 May 2023
+	monthFirstRe = regexp.MustCompile(`(?i)^` + monthPattern + `\.?[-._ ]?(\d{1,2})(?:(?:st|nd|rd|th),?[-._ ]?|,?[-._ ]|,)(\d{4}|\d{2})`)
+	// May 2023, which has no day to convert
+	monthYearRe = regexp.MustCompile(`(?i)^` + monthPattern + `\.?,?[-._ ]?(\d{4})`)
+
+	// What may sit between a date and the time after it: 2023-12-31 at
+	// 10.30.45, 2023-12-31_10.30.45
+	timeGapRe = regexp.MustCompile(`(?i)^(?:[ _]|[ _]at[ _])$`)
+	// 10.30.45, 10:30
+	timeRe = regexp.MustCompile(`^\d{1,2}(?:[.:]\d{2}){1,2}`)
+	// 31 Dec 2023, 31-dec-23, 31st December 2023
+	dayFirstRe = regexp.MustCompile(`(?i)^(\d{1,2})(?:st|nd|rd|th)?[-._ ]?` + monthPattern + `\.?,?[-._ ]?(\d{4}|\d{2})`)
+)
+
+// NormalizeDates renames entries under dir so that every date found in
+// their names is written as ISO 8601 (2023-12-31). Names without a
+// recognizable date, or with dates that are invalid or ambiguous, are
+// reported and left alone.
+func NormalizeDates(dir string, opts DateOptions) (int, error) {
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+
+	plan := NewPlan(dir)
+	var problems []string
+	for _, entry := range entries {
+		name := entry.Info.Name()
+		// The whole name is searched: "Scan 31.12.2023" has no real
+		// extension, and extensions never look like dates
+		newName, found, nameProblems := NormalizeDatesIn(name, opts.Order)
+
+		switch {
+		case len(nameProblems) > 0:
+			problems = append(problems, fmt.Sprintf("  %s: %s", entry.RelPath, strings.Join(nameProblems, "; ")))
+			continue
+		case found == 0:
+			problems = append(problems, fmt.Sprintf("  %s: no date found", entry.RelPath))
+			continue
+		case newName == name:
+			continue
+		}
+
+		if err := validName(newName); err != nil {
+			return 0, fmt.Errorf("renaming %s: %v", entry.RelPath, err)
+		}
+		if _, err := plan.Add(entry.Path, filepath.Join(filepath.Dir(entry.Path), newName), entry.Info.IsDir()); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("Could not parse dates in %d name(s), left unchanged:\n%s\n", len(problems), strings.Join(problems, "\n"))
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// NormalizeDatesIn rewrites every date in s as ISO 8601. It returns the new
+// string, how many dates were found, and a description of each date that
+// could not be converted; when there are problems s is returned unchanged.
+func NormalizeDatesIn(s string, order DateOrder) (string, int, []string) {
+	var b strings.Builder
+	var problems []string
+	found := 0
+	// lastEnd is where the last date ended, -1 before the first
+	lastEnd := -1
+
+	for i := 0; i < len(s); {
+		// Numbers right after a date are its time, as in macOS screenshots
+		if lastEnd >= 0 && isDigit(s[i:]) && timeGapRe.MatchString(s[lastEnd:i]) {
+			// More digits after it, as in 01.02.2024, make it a date
+			if t := timeRe.FindString(s[i:]); t != "" && !followedByDigit(s, i+len(t)) && !followedByDigit(s, i+len(t)+1) {
+				b.WriteString(t)
+				i += len(t)
+				lastEnd = -1
+				continue
+			}
+		}
+
+		text, date, problem := matchDate(s, i, order)
+		if text == "" {
+			// Copied as is, so that invalid UTF-8 survives
+			_, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+size])
+			i += size
+			continue
+		}
+
+		found++
+		if problem != "" {
+			problems = append(problems, problem)
+			b.WriteString(text)
+		} else {
+			b.WriteString(date.Format(isoDateLayout))
+		}
+		i += len(text)
+		lastEnd = i
+	}
+
+	if len(problems) > 0 {
+		return s, found, problems
+	}
+	return b.String(), found, nil
+}
+
+// matchDate looks for a date starting at s[i]. It returns the matched text
+// (empty when there is no date there) and either the date or the reason
+// it could not be converted.
+func matchDate(s string, i int, order DateOrder) (string, time.Time, string) {
+	rest := s[i:]
+	prev, _ := utf8.DecodeLastRuneInString(s[:i])
+
+	if isLetter(rest) && !unicode.IsLetter(prev) {
+		if m := monthFirstRe.FindStringSubmatch(rest); m != nil && !followedByDigit(s, i+len(m[0])) {
+			date, problem := namedMonthDate(m[0], m[2], m[1], m[3])
+			return m[0], date, problem
+		}
+		if m := monthYearRe.FindStringSubmatch(rest); m != nil && !followedByDigit(s, i+len(m[0])) && plausibleYear(m[2]) {
+			return m[0], time.Time{}, fmt.Sprintf("no full date in '%s'", m[0])
+		}
+		return "", time.Time{}, ""
+	}
+
+	// Digits after a letter v are a version number such as v1.02.03
+	if !isDigit(rest) || unicode.IsDigit(prev) || prev == 'v' || prev == 'V' {
+		return "", time.Time{}, ""
+	}
+
+	if m := dayFirstRe.FindStringSubmatch(rest); m != nil && !followedByDigit(s, i+len(m[0])) {
+		date, problem := namedMonthDate(m[0], m[1], m[2], m[3])
+		return m[0], date, problem
+	}
+
+	if m := numericDateRe.FindStringSubmatch(rest); m != nil && m[2] == m[4] && !followedByDigit(s, i+len(m[0])) {
+		if candidates, ok := numericCandidates(m[1], m[3], m[5]); ok {
+			date, problem := pickDate(m[0], candidates, order)
+			return m[0], date, problem
+		}
+	}
+
+	if m := compactDateRe.FindString(rest); m != "" && !followedByDigit(s, i+len(m)) {
+		candidates := map[DateOrder][3]string{
+			OrderYMD: {m[0:4], m[4:6], m[6:8]},
+			OrderDMY: {m[4:8], m[2:4], m[0:2]},
+			OrderMDY: {m[4:8], m[0:2], m[2:4]},
+		}
+		// Only plausible years, so that other 8-digit numbers are not dates
+		for o, c := range candidates {
+			if !plausibleYear(c[0]) {
+				delete(candidates, o)
+			}
+		}
+		if len(candidates) > 0 {
+			date, problem := pickDate(m, candidates, order)
+			if problem == "" || len(validDates(candidates)) > 0 {
+				return m, date, problem
+			}
+		}
+	}
+
+	return "", time.Time{}, ""
+}
+
+// numericCandidates lists the readings of a separated numeric date as
+// year, month, day strings. A four-digit first field can only be a year;
+// otherwise the year is last and day and month may be either way round.
+// With a two-digit year, day and month must be written the same width, so
+// that 1.02.03 is not taken for a date.
+func numericCandidates(a, b, c string) (map[DateOrder][3]string, bool) {
+	switch {
+	case len(a) == 4 && len(c) <= 2:
+		return map[DateOrder][3]string{OrderYMD: {a, b, c}}, true
+	case len(a) <= 2 && len(c) == 4:
+		return map[DateOrder][3]string{
+			OrderDMY: {c, b, a},
+			OrderMDY: {c, a, b},
+		}, true
+	case len(a) <= 2 && len(c) == 2 && len(a) == len(b):
+		candidates := map[DateOrder][3]string{
+			OrderDMY: {c, b, a},
+			OrderMDY: {c, a, b},
+		}
+		if len(a) == 2 {
+			// 23.12.31 style, only taken when asked for
+			candidates[OrderYMD] = [3]string{a, b, c}
+		}
+		return candidates, true
+	}
+	return nil, false
+}
+
+// pickDate chooses among the readings of a numeric date. A single valid
+// reading, or several that agree, is used directly; otherwise order
+// decides, and without it the date is ambiguous. A two-digit year is
+// always ambiguous without order: 10.30.45 is as likely a time as a date.
+func pickDate(text string, candidates map[DateOrder][3]string, order DateOrder) (time.Time, string) {
+	valid := validDates(candidates)
+	twoDigitYear := true
+	for _, c := range candidates {
+		twoDigitYear = twoDigitYear && len(c[0]) == 2
+	}
+	// A two-digit year first is too unusual to guess
+	if order != OrderYMD {
+		if c, exists := candidates[OrderYMD]; exists && len(c[0]) == 2 {
+			delete(valid, OrderYMD)
+		}
+	}
+
+	if len(valid) == 0 {
+		return time.Time{}, fmt.Sprintf("invalid date '%s'", text)
+	}
+	if twoDigitYear && order == "" {
+		return time.Time{}, fmt.Sprintf("ambiguous date '%s' (use -order=dmy, mdy or ymd)", text)
+	}
+
+	var first time.Time
+	agree := true
+	for _, date := range valid {
+		if first.IsZero() {
+			first = date
+		} else if !date.Equal(first) {
+			agree = false
+		}
+	}
+	if agree {
+		return first, ""
+	}
+
+	if date, exists := valid[order]; exists {
+		return date, ""
+	}
+	return time.Time{}, fmt.Sprintf("ambiguous date '%s' (use -order=dmy, mdy or ymd)", text)
+}
+
+// validDates converts the readings that form real calendar dates
+func validDates(candidates map[DateOrder][3]string) map[DateOrder]time.Time {
+	valid := make(map[DateOrder]time.Time)
+	for o, c := range candidates {
+		month, _ := strconv.Atoi(c[1])
+		if date, ok := makeDate(c[0], month, c[2]); ok {
+			valid[o] = date
+		}
+	}
+	return valid
+}
+
+// namedMonthDate converts a date written with a month name
+func namedMonthDate(text, day, month, year string) (time.Time, string) {
+	date, ok := makeDate(year, monthNumber(month), day)
+	if !ok {
+		return time.Time{}, fmt.Sprintf("invalid date '%s'", text)
+	}
+	return date, ""
+}
+
+// makeDate builds a date, rejecting days that do not exist such as 31 Feb
+// and years outside 1900-2099. Two-digit years below 70 are taken as 20xx, the others as 19xx.
+func makeDate(year string, month int, day string) (time.Time, bool) {
+	y, err := strconv.Atoi(year)
+	if err != nil || (len(year) != 2 && !plausibleYear(year)) {
+		return time.Time{}, false
+	}
+	if len(year) == 2 {
+		if y < 70 {
+			y += 2000
+		} else {
+			y += 1900
+		}
+	}
+	d, err := strconv.Atoi(day)
+	if err != nil || month < 1 || month > 12 || d < 1 || d > 31 {
+		return time.Time{}, false
+	}
+
+	date := time.Date(y, time.Month(month), d, 0, 0, 0, 0, time.UTC)
+	if date.Day() != d {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// monthNumber maps an English month name or abbreviation to 1-12
+func monthNumber(name string) int {
+	prefix := strings.ToLower(name)[:3]
+	for m := time.January; m <= time.December; m++ {
+		if strings.ToLower(m.String())[:3] == prefix {
+			return int(m)
+		}
+	}
+	return 0
+}
+
+// plausibleYear reports whether a four-digit year is in 1900-2099
+func plausibleYear(year string) bool {
+	y, err := strconv.Atoi(year)
+	return err == nil && len(year) == 4 && y >= 1900 && y <= 2099
+}
+
+func followedByDigit(s string, i int) bool {
+	return i < len(s) && s[i] >= '0' && s[i] <= '9'
+}
+
+func isDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+func isLetter(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r)
+}
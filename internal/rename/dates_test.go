@@ -0,0 +1,112 @@
+package rename
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeDatesIn(t *testing.T) {
+	tests := []struct {
+		in    string
+		order DateOrder
+		want  string
+		found int
+		// problems is the number of dates that could not be converted
+		problems int
+	}{
+		{"Scan 31.12.2023.pdf", "", "Scan 2023-12-31.pdf", 1, 0},
+		{"2023_12_31 notes.txt", "", "2023-12-31 notes.txt", 1, 0},
+		{"12-31-2023.txt", OrderDMY, "2023-12-31.txt", 1, 0},
+		{"20231231.log", "", "2023-12-31.log", 1, 0},
+		{"IMG 31122023.jpg", "", "IMG 2023-12-31.jpg", 1, 0},
+		{"Dec 31 2023 notes.txt", "", "2023-12-31 notes.txt", 1, 0},
+		{"December 31st, 2023.txt", "", "2023-12-31.txt", 1, 0},
+		{"dec-31-23.txt", "", "2023-12-31.txt", 1, 0},
+		{"3rd Mar 2020.txt", "", "2020-03-03.txt", 1, 0},
+		{"from 1.2.2023 to 5.2.2023", OrderDMY, "from 2023-02-01 to 2023-02-05", 2, 0},
+
+		// Ambiguous and impossible dates are reported
+		{"01.02.2023.txt", "", "01.02.2023.txt", 1, 1},
+		{"01.02.2023.txt", OrderDMY, "2023-02-01.txt", 1, 0},
+		{"01.02.2023.txt", OrderMDY, "2023-01-02.txt", 1, 0},
+		{"31.02.2023.txt", OrderDMY, "31.02.2023.txt", 1, 1},
+		{"23.12.31.txt", "", "23.12.31.txt", 1, 1},
+		{"23.12.31.txt", OrderDMY, "2031-12-23.txt", 1, 0},
+		{"23.12.31.txt", OrderYMD, "2023-12-31.txt", 1, 0},
+		{"31.12.23.txt", "", "31.12.23.txt", 1, 1},
+		{"01.02.03.txt", "", "01.02.03.txt", 1, 1},
+		{"01.02.03.txt", OrderYMD, "2001-02-03.txt", 1, 0},
+
+		// A month and year alone is not split into a day and a year
+		{"Report May 2023.pdf", "", "Report May 2023.pdf", 1, 1},
+		{"Report May 2023.pdf", OrderDMY, "Report May 2023.pdf", 1, 1},
+		{"May 2, 2023.txt", "", "2023-05-02.txt", 1, 0},
+
+		// A time after a date is left alone
+		{"Screenshot 2023-12-31 at 10.30.45.png", "", "Screenshot 2023-12-31 at 10.30.45.png", 1, 0},
+		{"Screenshot 2023-12-31 at 10.30.45.png", OrderDMY, "Screenshot 2023-12-31 at 10.30.45.png", 1, 0},
+		{"Screen Shot 31.12.2023 at 10.30.45 (2).png", OrderDMY, "Screen Shot 2023-12-31 at 10.30.45 (2).png", 1, 0},
+		{"IMG_20231231_10.30.45.jpg", "", "IMG_2023-12-31_10.30.45.jpg", 1, 0},
+		{"2023-12-31 10:30.txt", "", "2023-12-31 10:30.txt", 1, 0},
+		// but another date is not a time
+		{"2023-12-31_01.02.2024.txt", OrderDMY, "2023-12-31_2024-02-01.txt", 2, 0},
+		{"2023-12-31 to 2024-01-05.txt", "", "2023-12-31 to 2024-01-05.txt", 2, 0},
+		{"10.30.45.png", "", "10.30.45.png", 1, 1},
+
+		// Version numbers are not dates, whatever the order
+		{"v1.02.03.txt", "", "v1.02.03.txt", 0, 0},
+		{"v1.02.03.txt", OrderYMD, "v1.02.03.txt", 0, 0},
+		{"v1.02.03.txt", OrderDMY, "v1.02.03.txt", 0, 0},
+		{"v1.02.03.txt", OrderMDY, "v1.02.03.txt", 0, 0},
+		{"tool 1.02.03.txt", OrderDMY, "tool 1.02.03.txt", 0, 0},
+
+		// Years outside 1900-2099
+		{"0001-02-03.txt", "", "0001-02-03.txt", 1, 1},
+		{"12345678.bin", "", "12345678.bin", 0, 0},
+
+		// Bytes that are not UTF-8 are kept as they are
+		{"caf\xe9 2023-12-31.txt", "", "caf\xe9 2023-12-31.txt", 1, 0},
+		{"caf\xe9 31.12.2023.txt", "", "caf\xe9 2023-12-31.txt", 1, 0},
+
+		{"notes.txt", "", "notes.txt", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in+"/"+string(tt.order), func(t *testing.T) {
+			got, found, problems := NormalizeDatesIn(tt.in, tt.order)
+			if got != tt.want || found != tt.found || len(problems) != tt.problems {
+				t.Errorf("NormalizeDatesIn(%q, %q) = %q, %d, %q; want %q, %d, %d problem(s)",
+					tt.in, tt.order, got, found, problems, tt.want, tt.found, tt.problems)
+			}
+		})
+	}
+}
+
+func TestNumericCandidates(t *testing.T) {
+	tests := []struct {
+		a, b, c string
+		want    []DateOrder
+	}{
+		{"2023", "12", "31", []DateOrder{OrderYMD}},
+		{"31", "12", "2023", []DateOrder{OrderDMY, OrderMDY}},
+		{"1", "2", "2023", []DateOrder{OrderDMY, OrderMDY}},
+		{"23", "12", "31", []DateOrder{OrderDMY, OrderMDY, OrderYMD}},
+		{"1", "2", "03", []DateOrder{OrderDMY, OrderMDY}},
+		{"1", "02", "03", nil},
+		{"123", "02", "03", nil},
+		{"2023", "12", "2023", nil},
+	}
+
+	for _, tt := range tests {
+		candidates, ok := numericCandidates(tt.a, tt.b, tt.c)
+		var got []DateOrder
+		for _, o := range []DateOrder{OrderDMY, OrderMDY, OrderYMD} {
+			if _, exists := candidates[o]; exists {
+				got = append(got, o)
+			}
+		}
+		if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("numericCandidates(%q, %q, %q) = %v, %v; want %v", tt.a, tt.b, tt.c, got, ok, tt.want)
+		}
+	}
+}
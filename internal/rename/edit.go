@@ -0,0 +1,217 @@
+package rename
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EditOptions controls EditNames
+type EditOptions struct {
+	// Editor is the command used to edit the list; when empty $VISUAL,
+	// $EDITOR and finally vi are tried
+	Editor string
+	// AllowDelete deletes entries whose line was removed; without it a
+	// removed line is an error
+	AllowDelete bool
+	// Scope selects which files and directories are listed
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// EditNames writes the entries under dir to a temporary file, opens it in an
+// editor and turns the edited lines into renames, moves and deletions.
+func EditNames(dir string, opts EditOptions) (int, error) {
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		fmt.Println("Nothing to edit")
+		return 0, nil
+	}
+
+	tmpFile, err := os.CreateTemp("", "filekit-edit-*.txt")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(FormatEditList(entries))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write temporary file: %v", err)
+	}
+
+	if err := runEditor(opts.Editor, tmpFile.Name()); err != nil {
+		return 0, err
+	}
+
+	edited, err := os.ReadFile(tmpFile.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read edited file: %v", err)
+	}
+
+	plan, err := PlanEdits(dir, entries, edited, opts.AllowDelete)
+	if err != nil {
+		return 0, err
+	}
+	if len(plan.Renames) == 0 {
+		fmt.Println("No changes")
+		return 0, nil
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// FormatEditList renders entries as numbered lines, "ID<TAB>relative path"
+func FormatEditList(entries []Entry) []byte {
+	width := len(strconv.Itoa(len(entries)))
+
+	var b bytes.Buffer
+	b.WriteString("# Edit the paths to rename or move entries. Keep the numbers as they are.\n")
+	b.WriteString("# Deleting a line deletes the entry (only with -delete). Lines starting with # are ignored.\n")
+	for i, entry := range entries {
+		fmt.Fprintf(&b, "%0*d\t%s\n", width, i+1, filepath.ToSlash(entry.RelPath))
+	}
+	return b.Bytes()
+}
+
+// PlanEdits compares the edited list with the original entries. Changed
+// paths become renames or moves, missing lines become deletions.
+func PlanEdits(dir string, entries []Entry, edited []byte, allowDelete bool) (*Plan, error) {
+	newPaths := make(map[int]string, len(entries))
+
+	scanner := bufio.NewScanner(bytes.NewReader(edited))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		id, path, err := parseEditLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if id < 1 || id > len(entries) {
+			return nil, fmt.Errorf("line %d: unknown number %d", lineNo, id)
+		}
+		if _, exists := newPaths[id]; exists {
+			return nil, fmt.Errorf("line %d: number %d appears more than once", lineNo, id)
+		}
+
+		if path == "" || strings.HasSuffix(path, "/") {
+			return nil, fmt.Errorf("line %d: invalid path %q", lineNo, path)
+		}
+		newPath := filepath.Join(dir, filepath.FromSlash(path))
+		if !insideDir(dir, newPath) {
+			return nil, fmt.Errorf("line %d: %s is outside the directory", lineNo, path)
+		}
+		newPaths[id] = newPath
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read edited file: %v", err)
+	}
+
+	// A renamed directory carries its contents along, so child lines are
+	// read relative to where the directory ends up
+	movedDirs := make(map[string]string)
+	for i, entry := range entries {
+		if newPath, kept := newPaths[i+1]; kept && entry.Info.IsDir() && newPath != entry.Path {
+			movedDirs[entry.Path] = newPath
+		}
+	}
+
+	plan := NewPlan(dir)
+	plan.CreateDirs = true
+
+	for i, entry := range entries {
+		newPath, kept := newPaths[i+1]
+		if !kept {
+			if !allowDelete {
+				return nil, fmt.Errorf("the line for %s was removed; use -delete to allow deleting entries", entry.RelPath)
+			}
+			if _, err := plan.Remove(entry.Path, entry.Info.IsDir()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		newPath = insideMovedDir(dir, entry.Path, newPath, movedDirs)
+		if _, err := plan.Add(entry.Path, newPath, entry.Info.IsDir()); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// insideMovedDir maps newPath back under the old path of the closest moved
+// ancestor of oldPath whose new path contains it. Renames inside a directory
+// then happen before the directory moves, and entries that only follow
+// their directory are left out of the plan.
+func insideMovedDir(dir, oldPath, newPath string, movedDirs map[string]string) string {
+	for parent := filepath.Dir(oldPath); insideDir(dir, parent); parent = filepath.Dir(parent) {
+		movedTo, moved := movedDirs[parent]
+		if !moved || !insideDir(movedTo, newPath) {
+			continue
+		}
+		rel, err := filepath.Rel(movedTo, newPath)
+		if err != nil {
+			continue
+		}
+		return filepath.Join(parent, rel)
+	}
+	return newPath
+}
+
+// parseEditLine splits "ID<TAB or space>path"
+func parseEditLine(line string) (int, string, error) {
+	end := 0
+	for end < len(line) && line[end] >= '0' && line[end] <= '9' {
+		end++
+	}
+	if end == 0 || end == len(line) || (line[end] != '\t' && line[end] != ' ') {
+		return 0, "", fmt.Errorf("expected a number followed by a path")
+	}
+
+	id, err := strconv.Atoi(line[:end])
+	if err != nil {
+		return 0, "", err
+	}
+	return id, line[end+1:], nil
+}
+
+// runEditor opens path in the user's editor and waits for it to exit
+func runEditor(editor, path string) error {
+	if editor == "" {
+		editor = os.Getenv("VISUAL")
+	}
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// The editor setting may carry arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %v", fields[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// scriptEditor writes a shell script that edits the list with sed and
+// returns it for use as EditOptions.Editor
+func scriptEditor(t *testing.T, sedScript string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "editor.sh")
+	script := "#!/bin/sh\nsed -e '" + sedScript + "' \"$1\" > \"$1.new\" && mv \"$1.new\" \"$1\"\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEditNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		sed     string
+		dirs    bool
+		applied int
+		want    map[string]string
+	}{
+		{
+			name:    "file",
+			files:   map[string]string{"a.txt": "A", "b.txt": "B"},
+			sed:     "s/a.txt/c.txt/",
+			applied: 1,
+			want:    map[string]string{"c.txt": "A", "b.txt": "B"},
+		},
+		{
+			name:    "move into a new directory",
+			files:   map[string]string{"a.txt": "A"},
+			sed:     "s|a.txt|sub/a.txt|",
+			applied: 1,
+			want:    map[string]string{"sub/a.txt": "A"},
+		},
+		{
+			name:    "directory with a global substitution",
+			files:   map[string]string{"old/x.txt": "X", "old/y.txt": "Y"},
+			sed:     "s/old/new/g",
+			dirs:    true,
+			applied: 1,
+			want:    map[string]string{"new/x.txt": "X", "new/y.txt": "Y"},
+		},
+		{
+			name:    "directory and a file inside it",
+			files:   map[string]string{"old/x.txt": "X", "old/sub/y.txt": "Y"},
+			sed:     "s/old/new/g; s/x.txt/z.txt/",
+			dirs:    true,
+			applied: 2,
+			want:    map[string]string{"new/z.txt": "X", "new/sub/y.txt": "Y"},
+		},
+		{
+			name:    "nested directories",
+			files:   map[string]string{"a/b/x.txt": "X"},
+			sed:     "s|a|A|; s|/b|/B|",
+			dirs:    true,
+			applied: 2,
+			want:    map[string]string{"A/B/x.txt": "X"},
+		},
+		{
+			name:    "file out of a renamed directory",
+			files:   map[string]string{"old/x.txt": "X", "old/y.txt": "Y"},
+			sed:     "s/old/new/; s|new/x.txt|x.txt|",
+			dirs:    true,
+			applied: 2,
+			want:    map[string]string{"x.txt": "X", "new/y.txt": "Y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := makeTree(t, tt.files)
+			opts := EditOptions{
+				Editor:      scriptEditor(t, tt.sed),
+				Scope:       Scope{Dirs: tt.dirs},
+				ExecOptions: ExecOptions{OnConflict: ConflictAbort},
+			}
+
+			applied, err := EditNames(root, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if applied != tt.applied {
+				t.Errorf("applied %d renames, want %d", applied, tt.applied)
+			}
+			if got := readTree(t, root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tree = %v, want %v", got, tt.want)
+			}
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				inside, _ := os.ReadDir(filepath.Join(root, entry.Name()))
+				if entry.IsDir() && len(inside) == 0 {
+					t.Errorf("empty directory %s left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package rename
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// Charset is a legacy character set names may have been written in
+type Charset struct {
+	Name     string
+	encoding encoding.Encoding
+}
+
+// charsets are the supported charsets by canonical name
+var charsets = map[string]encoding.Encoding{
+	"latin1":      charmap.ISO8859_1,
+	"latin2":      charmap.ISO8859_2,
+	"iso-8859-15": charmap.ISO8859_15,
+	"cp1250":      charmap.Windows1250,
+	"cp1251":      charmap.Windows1251,
+	"cp1252":      charmap.Windows1252,
+	"cp437":       charmap.CodePage437,
+	"cp850":       charmap.CodePage850,
+	"cp866":       charmap.CodePage866,
+	"koi8-r":      charmap.KOI8R,
+	"macroman":    charmap.Macintosh,
+	"shift-jis":   japanese.ShiftJIS,
+	"euc-jp":      japanese.EUCJP,
+	"gbk":         simplifiedchinese.GBK,
+	"gb18030":     simplifiedchinese.GB18030,
+	"big5":        traditionalchinese.Big5,
+	"euc-kr":      korean.EUCKR,
+}
+
+// charsetAliases maps other common spellings to canonical names
+var charsetAliases = map[string]string{
+	"iso-8859-1":   "latin1",
+	"iso-8859-2":   "latin2",
+	"latin9":       "iso-8859-15",
+	"windows-1250": "cp1250",
+	"windows-1251": "cp1251",
+	"windows-1252": "cp1252",
+	"koi8r":        "koi8-r",
+	"mac":          "macroman",
+	"sjis":         "shift-jis",
+	"shift_jis":    "shift-jis",
+	"cp932":        "shift-jis",
+	"eucjp":        "euc-jp",
+	"cp936":        "gbk",
+	"cp950":        "big5",
+	"euckr":        "euc-kr",
+	"cp949":        "euc-kr",
+}
+
+// DefaultCharsets are tried when no charset is given: Western Windows names
+// and Japanese ones, the usual sources of broken names
+var DefaultCharsets = []string{"cp1252", "shift-jis"}
+
+// ParseCharsets parses a comma separated list of charset names
+func ParseCharsets(s string) ([]Charset, error) {
+	var list []Charset
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if canonical, exists := charsetAliases[name]; exists {
+			name = canonical
+		}
+		enc, exists := charsets[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown charset '%s' (supported: %s)", name, strings.Join(charsetNames(), ", "))
+		}
+		list = append(list, Charset{Name: name, encoding: enc})
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("empty charset list")
+	}
+	return list, nil
+}
+
+func charsetNames() []string {
+	names := make([]string, 0, len(charsets))
+	for name := range charsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EncodingOptions controls FixEncoding
+type EncodingOptions struct {
+	// Charsets are the charsets names may be in, in order of preference. A
+	// single charset is used for every name; with several, the one that
+	// decodes each name most plausibly is picked.
+	Charsets []Charset
+	// Scope selects which files and directories are visited
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// FixEncoding renames entries under dir whose names are not valid UTF-8 by
+// decoding them from a legacy charset. Each rename notes the charset used;
+// names that no charset decodes cleanly are reported and left alone.
+func FixEncoding(dir string, opts EncodingOptions) (int, error) {
+	if len(opts.Charsets) == 0 {
+		return 0, fmt.Errorf("no charsets given")
+	}
+
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+
+	plan := NewPlan(dir)
+	var problems []string
+	for _, entry := range entries {
+		name := entry.Info.Name()
+		if utf8.ValidString(name) {
+			continue
+		}
+
+		newName, charset, err := DecodeName(name, opts.Charsets)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("  %q: %v", entry.RelPath, err))
+			continue
+		}
+		if err := validName(newName); err != nil {
+			problems = append(problems, fmt.Sprintf("  %q: decodes to an unusable name: %v", entry.RelPath, err))
+			continue
+		}
+
+		r, err := plan.Add(entry.Path, filepath.Join(filepath.Dir(entry.Path), newName), entry.Info.IsDir())
+		if err != nil {
+			return 0, err
+		}
+		if r != nil {
+			r.Note = charset.Name
+			if len(opts.Charsets) > 1 {
+				r.Note += " (guessed)"
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("Could not decode %d name(s), left unchanged:\n%s\n", len(problems), strings.Join(problems, "\n"))
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// DecodeName converts name from the charset among charsets that decodes it
+// most plausibly. A decoding is rejected when it has undefined or control
+// characters; of the rest, the one where the most non-ASCII characters
+// are common letters wins, with ties going to the earlier charset.
+func DecodeName(name string, charsets []Charset) (string, Charset, error) {
+	best, bestCharset := "", Charset{}
+	bestPlausible, bestTotal := -1, 1
+	for _, charset := range charsets {
+		decoded, err := charset.encoding.NewDecoder().String(name)
+		if err != nil || !utf8.ValidString(decoded) {
+			continue
+		}
+		plausible, total, clean := scoreDecoding(decoded)
+		if !clean {
+			continue
+		}
+		// plausible/total > bestPlausible/bestTotal
+		if plausible*bestTotal > bestPlausible*total {
+			best, bestCharset = decoded, charset
+			bestPlausible, bestTotal = plausible, total
+		}
+	}
+
+	if bestPlausible < 0 {
+		names := make([]string, len(charsets))
+		for i, charset := range charsets {
+			names[i] = charset.Name
+		}
+		return "", Charset{}, fmt.Errorf("not valid in %s", strings.Join(names, ", "))
+	}
+	return best, bestCharset, nil
+}
+
+// scoreDecoding counts the non-ASCII characters of a decoded name and how
+// many of them are plausible in a real name. clean is false when the
+// decoding has replacement, control or private use characters, which
+// only come out of the wrong charset.
+func scoreDecoding(s string) (plausible, total int, clean bool) {
+	for _, r := range s {
+		if r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Co, r) {
+			return 0, 0, false
+		}
+		if r < utf8.RuneSelf {
+			continue
+		}
+		total++
+		if plausibleRune(r) {
+			plausible++
+		}
+	}
+	if total == 0 {
+		total = 1
+	}
+	return plausible, total, true
+}
+
+// plausibleRune reports whether r is a letter, digit or punctuation mark
+// that commonly appears in names. Symbols and rarer letters such as "ƒ"
+// are what mojibake from the wrong charset is made of.
+func plausibleRune(r rune) bool {
+	switch {
+	case r == '×' || r == '÷':
+		return false
+	case r >= 0xC0 && r <= 0x17F: // Latin-1 Supplement and Latin Extended-A letters
+		return true
+	case r >= 0x370 && r <= 0x4FF: // Greek and Cyrillic
+		return unicode.IsLetter(r)
+	case r >= 0x3000 && r <= 0x303F, r >= 0xFF01 && r <= 0xFF9F: // CJK punctuation, full and half width forms
+		return true
+	}
+	return unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han, unicode.Hangul)
+}
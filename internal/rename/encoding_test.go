@@ -0,0 +1,77 @@
+package rename
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeName(t *testing.T) {
+	encode := func(charset, s string) string {
+		encoded, err := charsets[charset].NewEncoder().String(s)
+		if err != nil {
+			t.Fatalf("encoding %q as %s: %v", s, charset, err)
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name        string
+		charsets    string
+		want        string
+		wantCharset string
+		wantErr     string
+	}{
+		{encode("cp1252", "café.txt"), "cp1252,shift-jis", "café.txt", "cp1252", ""},
+		{encode("cp1252", "Müller – Straße.txt"), "cp1252,shift-jis", "Müller – Straße.txt", "cp1252", ""},
+		{encode("shift-jis", "日本語.txt"), "cp1252,shift-jis", "日本語.txt", "shift-jis", ""},
+		{encode("shift-jis", "テスト_01.jpg"), "cp1252,shift-jis", "テスト_01.jpg", "shift-jis", ""},
+		{encode("cp1251", "Привет.doc"), "cp1251,cp1252", "Привет.doc", "cp1251", ""},
+		{encode("euc-kr", "한국어.txt"), "cp1252,euc-kr", "한국어.txt", "euc-kr", ""},
+
+		// Symbols lose to letters: "a×b" in cp1252 reads as half-width
+		// katakana in Shift-JIS
+		{"a\xd7b", "cp1252,shift-jis", "aﾗb", "shift-jis", ""},
+		// Equally plausible decodings go to the charset listed first
+		{encode("cp1251", "Привет"), "cp1252,cp1251", "Ïðèâåò", "cp1252", ""},
+		// A control character rules a charset out
+		{"a\x85b", "latin1,cp1252", "a…b", "cp1252", ""},
+		{"a\x85b", "latin1", "", "", "not valid in latin1"},
+	}
+
+	for _, tt := range tests {
+		list, err := ParseCharsets(tt.charsets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, charset, err := DecodeName(tt.name, list)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DecodeName(%q, %s) error = %v, want %q", tt.name, tt.charsets, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want || charset.Name != tt.wantCharset {
+			t.Errorf("DecodeName(%q, %s) = %q, %s, %v; want %q, %s", tt.name, tt.charsets, got, charset.Name, err, tt.want, tt.wantCharset)
+		}
+	}
+}
+
+func TestParseCharsets(t *testing.T) {
+	list, err := ParseCharsets(" Windows-1252, sjis,,koi8-r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, charset := range list {
+		names = append(names, charset.Name)
+	}
+	if got := strings.Join(names, ","); got != "cp1252,shift-jis,koi8-r" {
+		t.Errorf("charsets = %s, want cp1252,shift-jis,koi8-r", got)
+	}
+
+	for _, s := range []string{"utf-16", "", " , "} {
+		if _, err := ParseCharsets(s); err == nil {
+			t.Errorf("ParseCharsets(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package rename
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MapRow is one old→new pair read from a mapping file
+type MapRow struct {
+	// Line is the line (CSV/TSV) or item number (JSON) the row came from
+	Line int
+	From string
+	To   string
+}
+
+// ReadMapping reads a mapping file. format is "csv", "tsv" or "json"; when
+// empty it is taken from the file extension.
+//
+// CSV and TSV files have the old name in the first column and the new name in
+// the second; a header row such as "from,to" or "old,new" is skipped. JSON
+// files hold either an object {"old": "new", ...} or an array of
+// {"from": "old", "to": "new"} objects.
+func ReadMapping(path, format string) ([]MapRow, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %v", err)
+	}
+
+	switch strings.ToLower(format) {
+	case "csv":
+		return readDelimitedMapping(data, ',')
+	case "tsv", "tab":
+		return readDelimitedMapping(data, '\t')
+	case "json":
+		return readJSONMapping(data)
+	}
+	return nil, fmt.Errorf("unsupported mapping format '%s' (expected csv, tsv or json)", format)
+}
+
+// readDelimitedMapping parses CSV or TSV rows
+func readDelimitedMapping(data []byte, comma rune) ([]MapRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if comma == '\t' {
+		// Names may contain quotes; TSV exports rarely quote fields
+		reader.LazyQuotes = true
+	}
+
+	var rows []MapRow
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid mapping file: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected two columns, got %d", line, len(record))
+		}
+		if first && isMappingHeader(record[0], record[1]) {
+			continue
+		}
+
+		rows = append(rows, MapRow{Line: line, From: record[0], To: record[1]})
+	}
+
+	return rows, nil
+}
+
+// isMappingHeader reports whether a first row looks like column titles
+func isMappingHeader(from, to string) bool {
+	headers := map[string]string{
+		"from":   "to",
+		"old":    "new",
+		"source": "target",
+		"src":    "dst",
+	}
+	want, exists := headers[strings.ToLower(strings.TrimSpace(from))]
+	return exists && want == strings.ToLower(strings.TrimSpace(to))
+}
+
+// readJSONMapping parses either an object or an array of from/to objects,
+// keeping the order of the file
+func readJSONMapping(data []byte) ([]MapRow, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	if trimmed[0] == '[' {
+		var items []struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+			Old  string `json:"old"`
+			New  string `json:"new"`
+		}
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, fmt.Errorf("invalid mapping file: %v", err)
+		}
+
+		rows := make([]MapRow, 0, len(items))
+		for i, item := range items {
+			row := MapRow{Line: i + 1, From: item.From, To: item.To}
+			if row.From == "" && row.To == "" {
+				row.From, row.To = item.Old, item.New
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	// Decode the object token by token, because a map loses the order
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("invalid mapping file: expected a JSON object or array")
+	}
+
+	var rows []MapRow
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid mapping file: %v", err)
+		}
+		var value string
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid mapping file: value for %v: %v", key, err)
+		}
+		rows = append(rows, MapRow{Line: len(rows) + 1, From: key.(string), To: value})
+	}
+	return rows, nil
+}
+
+// PlanMapping turns mapping rows into a plan. Paths are relative to dir
+// unless absolute. Rows that cannot be applied (missing source, a source
+// listed twice, a path outside dir) are kept in the plan as skipped so they
+// show up in the report. Each rename is labelled with its row number.
+func PlanMapping(dir string, rows []MapRow) (*Plan, error) {
+	plan := NewPlan(dir)
+	seen := make(map[string]int)
+
+	for _, row := range rows {
+		label := fmt.Sprintf("%d", row.Line)
+		from := resolveMapPath(dir, row.From)
+		to := resolveMapPath(dir, row.To)
+
+		skip := func(reason string) {
+			plan.Skip(from, to, false, reason).Label = label
+		}
+
+		switch {
+		case strings.TrimSpace(row.From) == "" || strings.TrimSpace(row.To) == "":
+			skip("empty name")
+			continue
+		case !insideDir(dir, from) || !insideDir(dir, to):
+			skip("path is outside the directory")
+			continue
+		case from == to:
+			skip("name is unchanged")
+			continue
+		}
+		if line, exists := seen[from]; exists {
+			skip(fmt.Sprintf("source already renamed on row %d", line))
+			continue
+		}
+
+		info, err := os.Lstat(from)
+		if err != nil {
+			skip("source does not exist")
+			continue
+		}
+
+		r, err := plan.Add(from, to, info.IsDir())
+		if err != nil {
+			return nil, err
+		}
+		r.Label = label
+		seen[from] = row.Line
+	}
+
+	return plan, nil
+}
+
+// resolveMapPath makes a mapping path absolute relative to dir
+func resolveMapPath(dir, path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return dir
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
+
+// insideDir reports whether path lies strictly below dir
+func insideDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
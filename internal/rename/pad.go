@@ -0,0 +1,201 @@
+package rename
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NumberSelect picks which numbers in a name are padded
+type NumberSelect string
+
+const (
+	NumbersFirst NumberSelect = "first"
+	NumbersLast  NumberSelect = "last"
+	NumbersAll   NumberSelect = "all"
+)
+
+// ParseNumberSelect converts a flag value into a NumberSelect
+func ParseNumberSelect(s string) (NumberSelect, error) {
+	switch n := NumberSelect(strings.ToLower(s)); n {
+	case NumbersFirst, NumbersLast, NumbersAll:
+		return n, nil
+	}
+	return "", fmt.Errorf("invalid number selection '%s' (expected first, last or all)", s)
+}
+
+// PadOptions controls PadNumbers
+type PadOptions struct {
+	// Width pads to a fixed number of digits; 0 pads to the widest number in
+	// each directory
+	Width int
+	// Numbers selects which numbers are changed; the zero value means all
+	Numbers NumberSelect
+	// Strip removes padding instead of adding it
+	Strip bool
+	// Part selects the part of the name to look for numbers in; the zero
+	// value means the full name
+	Part NamePart
+	// Scope selects which files and directories are visited
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+var digitsRe = regexp.MustCompile(`[0-9]+`)
+
+// PadNumbers renames entries under dir so that numbers in their names sort
+// naturally: "ep1", "ep10" become "ep01", "ep10". With Strip, "ep01" becomes
+// "ep1" again.
+func PadNumbers(dir string, opts PadOptions) (int, error) {
+	if opts.Width < 0 {
+		return 0, fmt.Errorf("invalid width %d", opts.Width)
+	}
+	if opts.Numbers == "" {
+		opts.Numbers = NumbersAll
+	}
+
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+
+	plan := NewPlan(dir)
+	for _, group := range groupByDir(entries) {
+		widths := make(map[numberKey]int)
+		if !opts.Strip && opts.Width == 0 {
+			widths = widestNumbers(group, opts)
+		}
+
+		for _, entry := range group {
+			name := entry.Info.Name()
+			newName, _ := ApplyToPart(name, entry.Info.IsDir(), opts.Part, func(s string) (string, bool) {
+				return padRuns(s, opts, widths), true
+			})
+			if newName == name {
+				continue
+			}
+			if err := validName(newName); err != nil {
+				return 0, fmt.Errorf("renaming %s: %v", entry.RelPath, err)
+			}
+
+			_, err := plan.Add(entry.Path, filepath.Join(filepath.Dir(entry.Path), newName), entry.Info.IsDir())
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// numberKey is what a number is measured under when padding to the widest
+// number of a directory
+type numberKey struct {
+	// label is the word right before the number, e.g. "ep" in "ep1" or
+	// "show" in "Show 2024"
+	label string
+	// nth counts earlier numbers with the same label in the name
+	nth int
+}
+
+// numberKeys returns the positions of the selected numbers among the runs
+// found at locs in s, and the key each is measured under. With all numbers,
+// a number is keyed by the word before it, so names with different counts
+// of numbers line up: the year in "Show 2024 ep3" does not widen the
+// episode in "ep1".
+func numberKeys(s string, locs [][]int, numbers NumberSelect) map[int]numberKey {
+	keys := make(map[int]numberKey)
+	if len(locs) == 0 {
+		return keys
+	}
+	switch numbers {
+	case NumbersFirst:
+		keys[0] = numberKey{}
+	case NumbersLast:
+		keys[len(locs)-1] = numberKey{}
+	default:
+		seen := make(map[string]int)
+		prev := 0
+		for i, loc := range locs {
+			label := numberLabel(s[prev:loc[0]])
+			keys[i] = numberKey{label, seen[label]}
+			seen[label]++
+			prev = loc[1]
+		}
+	}
+	return keys
+}
+
+// numberLabel returns the last word of the text before a number, lower
+// cased, skipping separators between the word and the number
+func numberLabel(before string) string {
+	notLetter := func(r rune) bool { return !unicode.IsLetter(r) }
+	word := strings.TrimRightFunc(before, notLetter)
+	if i := strings.LastIndexFunc(word, notLetter); i >= 0 {
+		_, size := utf8.DecodeRuneInString(word[i:])
+		word = word[i+size:]
+	}
+	return strings.ToLower(word)
+}
+
+// widestNumbers measures the selected numbers of a directory, ignoring
+// existing leading zeros
+func widestNumbers(group []Entry, opts PadOptions) map[numberKey]int {
+	widths := make(map[numberKey]int)
+	for _, entry := range group {
+		ApplyToPart(entry.Info.Name(), entry.Info.IsDir(), opts.Part, func(s string) (string, bool) {
+			locs := digitsRe.FindAllStringIndex(s, -1)
+			for i, key := range numberKeys(s, locs, opts.Numbers) {
+				widths[key] = max(widths[key], len(stripZeros(s[locs[i][0]:locs[i][1]])))
+			}
+			return s, true
+		})
+	}
+	return widths
+}
+
+// padRuns rewrites the selected numbers in s
+func padRuns(s string, opts PadOptions, widths map[numberKey]int) string {
+	locs := digitsRe.FindAllStringIndex(s, -1)
+	keys := numberKeys(s, locs, opts.Numbers)
+
+	var b strings.Builder
+	last := 0
+	for i, loc := range locs {
+		key, selected := keys[i]
+		if !selected {
+			continue
+		}
+		b.WriteString(s[last:loc[0]])
+
+		// Padding only adds zeros; numbers already as wide are kept as is
+		digits := s[loc[0]:loc[1]]
+		width := opts.Width
+		if width == 0 {
+			width = widths[key]
+		}
+		switch {
+		case opts.Strip:
+			digits = stripZeros(digits)
+		case len(digits) < width:
+			digits = strings.Repeat("0", width-len(digits)) + digits
+		}
+		b.WriteString(digits)
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// stripZeros removes leading zeros, keeping a single "0"
+func stripZeros(digits string) string {
+	trimmed := strings.TrimLeft(digits, "0")
+	if trimmed == "" {
+		return "0"
+	}
+	return trimmed
+}
@@ -0,0 +1,73 @@
+package rename
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPadNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		opts  PadOptions
+		want  []string
+	}{
+		{
+			name:  "widest in the directory",
+			files: []string{"ep1.mkv", "ep2.mkv", "ep10.mkv"},
+			want:  []string{"ep01.mkv", "ep02.mkv", "ep10.mkv"},
+		},
+		{
+			name:  "a year in some names",
+			files: []string{"ep1.mkv", "ep2.mkv", "ep10.mkv", "Show 2024 ep3.mkv"},
+			want:  []string{"ep01.mkv", "ep02.mkv", "ep10.mkv", "Show 2024 ep03.mkv"},
+		},
+		{
+			name:  "season and episode",
+			files: []string{"S1E2.mkv", "S1E10.mkv", "S10E1.mkv"},
+			want:  []string{"S01E02.mkv", "S01E10.mkv", "S10E01.mkv"},
+		},
+		{
+			name:  "repeated label",
+			files: []string{"disc 1 track 1.flac", "disc 1 track 12.flac", "disc 2 track 3.flac"},
+			want:  []string{"disc 1 track 01.flac", "disc 1 track 12.flac", "disc 2 track 03.flac"},
+		},
+		{
+			name:  "fixed width",
+			files: []string{"a1.txt", "a12345.txt"},
+			opts:  PadOptions{Width: 3},
+			want:  []string{"a001.txt", "a12345.txt"},
+		},
+		{
+			name:  "last number only",
+			files: []string{"2024 ep1.mkv", "2024 ep10.mkv"},
+			opts:  PadOptions{Numbers: NumbersLast},
+			want:  []string{"2024 ep01.mkv", "2024 ep10.mkv"},
+		},
+		{
+			name:  "strip",
+			files: []string{"ep001.mkv", "ep0.mkv"},
+			opts:  PadOptions{Strip: true},
+			want:  []string{"ep1.mkv", "ep0.mkv"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string]string)
+			want := make(map[string]string)
+			for i, name := range tt.files {
+				files[name] = name
+				want[tt.want[i]] = name
+			}
+			root := makeTree(t, files)
+
+			if _, err := PadNumbers(root, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+				t.Errorf("tree = %v, want %v", got, want)
+			}
+		})
+	}
+}
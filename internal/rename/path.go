@@ -0,0 +1,99 @@
+package rename
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PathOptions controls RewritePaths
+type PathOptions struct {
+	// Regex treats target as a Go regular expression and allows $1 / ${name}
+	// expansions in replaceWith
+	Regex bool
+	// IgnoreCase matches target case-insensitively
+	IgnoreCase bool
+	// RemoveEmptyDirs removes directories left empty by the moves
+	RemoveEmptyDirs bool
+	// Scope selects which files are visited; directories are never moved
+	// themselves, they are created and removed as needed
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// RewritePaths moves files under dir by replacing target with replaceWith
+// in their path relative to dir, written with forward slashes, e.g.
+// "Season 1/Show - 01.mkv". Missing target directories are created.
+func RewritePaths(dir, target, replaceWith string, opts PathOptions) (int, error) {
+	if opts.Dirs || opts.DirsOnly {
+		return 0, fmt.Errorf("rename-path moves files only; directories are created and removed as needed")
+	}
+
+	replace, err := newReplacer(target, replaceWith, Options{Regex: opts.Regex, IgnoreCase: opts.IgnoreCase})
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+
+	plan, err := PlanPathChanges(dir, entries, replace)
+	if err != nil {
+		return 0, err
+	}
+	plan.RemoveEmptyDirs = opts.RemoveEmptyDirs
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// PlanPathChanges builds a plan that moves each entry to the relative path
+// fn returns. Entries fn declines are left alone.
+func PlanPathChanges(root string, entries []Entry, fn func(string) (string, bool)) (*Plan, error) {
+	plan := NewPlan(root)
+	plan.CreateDirs = true
+
+	for _, entry := range entries {
+		relPath := filepath.ToSlash(entry.RelPath)
+		newRel, ok := fn(relPath)
+		if !ok || newRel == relPath {
+			continue
+		}
+
+		newPath, err := validRelPath(root, newRel)
+		if err != nil {
+			return nil, fmt.Errorf("renaming %s: %v", relPath, err)
+		}
+
+		if _, err := plan.Add(entry.Path, newPath, entry.Info.IsDir()); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// validRelPath checks a rewritten relative path and returns it joined to
+// root. Empty elements from doubled or trailing slashes are rejected
+// rather than silently cleaned up.
+func validRelPath(root, rel string) (string, error) {
+	if rel == "" || strings.HasPrefix(rel, "/") {
+		return "", fmt.Errorf("invalid new path %q", rel)
+	}
+	for _, element := range strings.Split(rel, "/") {
+		if element == "" || element == "." || element == ".." {
+			return "", fmt.Errorf("invalid new path %q", rel)
+		}
+		if filepath.Separator != '/' && strings.ContainsRune(element, filepath.Separator) {
+			return "", fmt.Errorf("new path %q contains a path separator", rel)
+		}
+	}
+
+	newPath := filepath.Join(root, filepath.FromSlash(rel))
+	if !insideDir(root, newPath) {
+		return "", fmt.Errorf("new path %q is outside the directory", rel)
+	}
+	return newPath, nil
+}
@@ -0,0 +1,73 @@
+package rename
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidRelPath(t *testing.T) {
+	root := filepath.FromSlash("/root")
+	tests := []struct {
+		rel     string
+		want    string
+		wantErr string
+	}{
+		{"a.txt", "a.txt", ""},
+		{"Season 1/Show - 01.mkv", "Season 1/Show - 01.mkv", ""},
+		{"a/b/c/d.txt", "a/b/c/d.txt", ""},
+		{"..hidden/.x", "..hidden/.x", ""},
+		{"", "", "invalid new path"},
+		{"/etc/passwd", "", "invalid new path"},
+		{"a//b.txt", "", "invalid new path"},
+		{"a/b/", "", "invalid new path"},
+		{"./a.txt", "", "invalid new path"},
+		{"a/./b.txt", "", "invalid new path"},
+		{"../a.txt", "", "invalid new path"},
+		{"a/../../b.txt", "", "invalid new path"},
+		{"a/..", "", "invalid new path"},
+	}
+
+	for _, tt := range tests {
+		got, err := validRelPath(root, tt.rel)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validRelPath(%q) = %q, %v; want error %q", tt.rel, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); err != nil || got != want {
+			t.Errorf("validRelPath(%q) = %q, %v; want %q", tt.rel, got, err, want)
+		}
+	}
+}
+
+func TestRewritePaths(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"Show - S01E01.mkv": "1",
+		"Show - S01E02.mkv": "2",
+		"Show - S02E01.mkv": "3",
+		"notes.txt":         "n",
+	})
+
+	opts := PathOptions{Regex: true, ExecOptions: ExecOptions{OnConflict: ConflictAbort}}
+	if _, err := RewritePaths(root, `^Show - S(\d+)E(\d+)`, "Season $1/Episode $2", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"Season 01/Episode 01.mkv": "1",
+		"Season 01/Episode 02.mkv": "2",
+		"Season 02/Episode 01.mkv": "3",
+		"notes.txt":                "n",
+	}
+	if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+
+	// A path that would leave the directory stops the whole plan
+	if _, err := RewritePaths(root, "notes.txt", "../notes.txt", PathOptions{}); err == nil || !strings.Contains(err.Error(), "invalid new path") {
+		t.Errorf("error = %v, want an invalid path", err)
+	}
+}
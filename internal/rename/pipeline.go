@@ -0,0 +1,338 @@
+package rename
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCollapseChars are the separators collapse squeezes by default
+const DefaultCollapseChars = " _-."
+
+// Step is one operation of a rename pipeline. Which fields are used depends
+// on Op:
+//
+//	prefix   Text       add Text at the start
+//	suffix   Text       add Text at the end (before the extension for the stem)
+//	insert   Pos, Text  insert Text at rune position Pos
+//	remove   Pos, Count remove Count runes starting at Pos
+//	trim     Chars      trim Chars (default whitespace) from both ends
+//	collapse Chars      squeeze runs of the same separator from Chars into one
+//	replace  Old, New   replace every Old with New (IgnoreCase optional)
+//	regex    Pattern, Replace  regular expression replace with $1 expansions
+//	case     Mode       any rename-case mode: lower, upper, title, snake, ...
+//
+// Negative positions count from the end, so remove with Pos -3 and Count 3
+// drops the last three characters.
+type Step struct {
+	Op         string `json:"op" yaml:"op"`
+	Text       string `json:"text,omitempty" yaml:"text,omitempty"`
+	Pos        int    `json:"pos,omitempty" yaml:"pos,omitempty"`
+	Count      int    `json:"count,omitempty" yaml:"count,omitempty"`
+	Chars      string `json:"chars,omitempty" yaml:"chars,omitempty"`
+	Old        string `json:"old,omitempty" yaml:"old,omitempty"`
+	New        string `json:"new,omitempty" yaml:"new,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty" yaml:"ignore_case,omitempty"`
+	Pattern    string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Replace    string `json:"replace,omitempty" yaml:"replace,omitempty"`
+	Mode       string `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// Recipe is a shareable pipeline definition, read from JSON or YAML
+type Recipe struct {
+	// Part is the part of the name the steps apply to; empty means stem
+	Part  string `json:"part,omitempty" yaml:"part,omitempty"`
+	Steps []Step `json:"steps" yaml:"steps"`
+}
+
+// PipelineOptions controls RunPipeline
+type PipelineOptions struct {
+	// Part selects the part of the name the steps apply to; the zero value
+	// means the full name
+	Part NamePart
+	// Scope selects which files and directories are visited
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// RunPipeline renames entries under dir by passing each name through steps
+// in order, every step working on the result of the previous one. With
+// steps that work on characters, names that are not valid UTF-8 are
+// reported and left alone.
+func RunPipeline(dir string, steps []Step, opts PipelineOptions) (int, error) {
+	transform, err := CompilePipeline(steps)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+	for _, step := range steps {
+		if step.needsUTF8() {
+			entries = skipInvalidUTF8(entries)
+			break
+		}
+	}
+
+	plan, err := PlanNameChanges(dir, entries, opts.Part, func(name string) (string, bool) {
+		return transform(name), true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// CompilePipeline validates steps and chains them into one function
+func CompilePipeline(steps []Step) (func(string) string, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("the pipeline has no steps")
+	}
+
+	funcs := make([]func(string) string, len(steps))
+	for i, step := range steps {
+		fn, err := step.compile()
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %v", i+1, step.Op, err)
+		}
+		funcs[i] = fn
+	}
+
+	return func(name string) string {
+		for _, fn := range funcs {
+			name = fn(name)
+		}
+		return name
+	}, nil
+}
+
+// needsUTF8 reports whether the step works on characters, which would
+// replace the bytes of a name that is not valid UTF-8
+func (s Step) needsUTF8() bool {
+	switch strings.ToLower(s.Op) {
+	case "insert", "remove", "case":
+		return true
+	}
+	return false
+}
+
+// compile turns a step into the function that applies it
+func (s Step) compile() (func(string) string, error) {
+	switch strings.ToLower(s.Op) {
+	case "prefix":
+		return func(name string) string { return s.Text + name }, nil
+
+	case "suffix":
+		return func(name string) string { return name + s.Text }, nil
+
+	case "insert":
+		return func(name string) string {
+			runes := []rune(name)
+			at := runePosition(s.Pos, len(runes))
+			return string(runes[:at]) + s.Text + string(runes[at:])
+		}, nil
+
+	case "remove":
+		if s.Count < 1 {
+			return nil, fmt.Errorf("count must be at least 1")
+		}
+		return func(name string) string {
+			runes := []rune(name)
+			start := runePosition(s.Pos, len(runes))
+			end := min(start+s.Count, len(runes))
+			return string(runes[:start]) + string(runes[end:])
+		}, nil
+
+	case "trim":
+		if s.Chars == "" {
+			return strings.TrimSpace, nil
+		}
+		return func(name string) string { return strings.Trim(name, s.Chars) }, nil
+
+	case "collapse":
+		chars := s.Chars
+		if chars == "" {
+			chars = DefaultCollapseChars
+		}
+		return func(name string) string { return collapseRuns(name, chars) }, nil
+
+	case "replace":
+		if s.Old == "" {
+			return nil, fmt.Errorf("old text is required")
+		}
+		replace, err := newReplacer(s.Old, s.New, Options{IgnoreCase: s.IgnoreCase})
+		if err != nil {
+			return nil, err
+		}
+		return func(name string) string {
+			newName, _ := replace(name)
+			return newName
+		}, nil
+
+	case "regex":
+		if s.Pattern == "" {
+			return nil, fmt.Errorf("pattern is required")
+		}
+		replace, err := newReplacer(s.Pattern, s.Replace, Options{Regex: true, IgnoreCase: s.IgnoreCase})
+		if err != nil {
+			return nil, err
+		}
+		return func(name string) string {
+			newName, _ := replace(name)
+			return newName
+		}, nil
+
+	case "case":
+		mode, err := ParseCaseMode(s.Mode)
+		if err != nil {
+			return nil, err
+		}
+		return func(name string) string { return ConvertCase(name, mode) }, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation '%s' (expected prefix, suffix, insert, remove, trim, collapse, replace, regex or case)", s.Op)
+}
+
+// runePosition resolves a possibly negative position into [0, length]
+func runePosition(pos, length int) int {
+	if pos < 0 {
+		pos += length
+	}
+	return max(0, min(pos, length))
+}
+
+// collapseRuns squeezes runs of the same character from chars into one,
+// e.g. "a__b--c" to "a_b-c"
+func collapseRuns(s, chars string) string {
+	var b strings.Builder
+	var last rune = -1
+	for _, r := range s {
+		if r == last && strings.ContainsRune(chars, r) {
+			continue
+		}
+		b.WriteRune(r)
+		last = r
+	}
+	return b.String()
+}
+
+// stepSyntax is the command line form of an operation: its arguments in
+// order, of which the first required ones must be given
+type stepSyntax struct {
+	args     []string
+	required int
+}
+
+var stepSyntaxes = map[string]stepSyntax{
+	"prefix":   {[]string{"text"}, 1},
+	"suffix":   {[]string{"text"}, 1},
+	"insert":   {[]string{"pos", "text"}, 2},
+	"remove":   {[]string{"pos", "count"}, 2},
+	"trim":     {[]string{"chars"}, 0},
+	"collapse": {[]string{"chars"}, 0},
+	"replace":  {[]string{"old", "new"}, 1},
+	"regex":    {[]string{"pattern", "replace"}, 1},
+	"case":     {[]string{"mode"}, 1},
+}
+
+// ParseStep parses the command line form of a step: the operation name, a
+// delimiter of your choice and the arguments separated by that delimiter,
+// e.g. "prefix:2024_", "remove:0:4" or "regex/(?:IMG|DSC)_(\d+)/Photo $1".
+// The last argument takes the rest of the text, delimiters included.
+func ParseStep(s string) (Step, error) {
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	op, rest := s, ""
+	if end >= 0 {
+		op, rest = s[:end], s[end:]
+	}
+	op = strings.ToLower(op)
+
+	syntax, known := stepSyntaxes[op]
+	if !known {
+		return Step{}, fmt.Errorf("unknown operation in '%s'", s)
+	}
+
+	var args []string
+	if rest != "" {
+		delim, size := utf8.DecodeRuneInString(rest)
+		args = strings.SplitN(rest[size:], string(delim), len(syntax.args))
+	}
+	if len(args) < syntax.required {
+		return Step{}, fmt.Errorf("'%s' needs %s", s, strings.Join(syntax.args[:syntax.required], " and "))
+	}
+
+	names := syntax.args
+	step := Step{Op: op}
+	for i, arg := range args {
+		switch names[i] {
+		case "text":
+			step.Text = arg
+		case "chars":
+			step.Chars = arg
+		case "old":
+			step.Old = arg
+		case "new":
+			step.New = arg
+		case "pattern":
+			step.Pattern = arg
+		case "replace":
+			step.Replace = arg
+		case "mode":
+			step.Mode = arg
+		case "pos", "count":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return Step{}, fmt.Errorf("invalid %s '%s' in '%s'", names[i], arg, s)
+			}
+			if names[i] == "pos" {
+				step.Pos = n
+			} else {
+				step.Count = n
+			}
+		}
+	}
+
+	if _, err := step.compile(); err != nil {
+		return Step{}, fmt.Errorf("'%s': %v", s, err)
+	}
+	return step, nil
+}
+
+// LoadRecipe reads a pipeline recipe from a .json, .yaml or .yml file
+func LoadRecipe(path string) (*Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe: %v", err)
+	}
+
+	recipe := &Recipe{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, recipe)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, recipe)
+	default:
+		return nil, fmt.Errorf("unknown recipe format '%s' (expected .json, .yaml or .yml)", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse recipe %s: %v", path, err)
+	}
+
+	for i, step := range recipe.Steps {
+		if _, err := step.compile(); err != nil {
+			return nil, fmt.Errorf("recipe step %d (%s): %v", i+1, step.Op, err)
+		}
+	}
+	return recipe, nil
+}
@@ -0,0 +1,54 @@
+package rename
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStep(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Step
+		wantErr string
+	}{
+		{"prefix:2024_", Step{Op: "prefix", Text: "2024_"}, ""},
+		{"Suffix:_final", Step{Op: "suffix", Text: "_final"}, ""},
+		{"prefix:a:b", Step{Op: "prefix", Text: "a:b"}, ""},
+		{"insert:-4: (copy)", Step{Op: "insert", Pos: -4, Text: " (copy)"}, ""},
+		{"remove:0:4", Step{Op: "remove", Pos: 0, Count: 4}, ""},
+		{"trim", Step{Op: "trim"}, ""},
+		{"trim:_-", Step{Op: "trim", Chars: "_-"}, ""},
+		{"collapse", Step{Op: "collapse"}, ""},
+		{"replace:old:new", Step{Op: "replace", Old: "old", New: "new"}, ""},
+		{"replace:old", Step{Op: "replace", Old: "old"}, ""},
+		{"replace|a:b|c:d", Step{Op: "replace", Old: "a:b", New: "c:d"}, ""},
+		{"replace→ → _", Step{Op: "replace", Old: " ", New: " _"}, ""},
+		{`regex/(?:IMG|DSC)_(\d+)/Photo $1`, Step{Op: "regex", Pattern: `(?:IMG|DSC)_(\d+)`, Replace: "Photo $1"}, ""},
+		{"regex#a/b#c/d#e", Step{Op: "regex", Pattern: "a/b", Replace: "c/d#e"}, ""},
+		{"case:snake", Step{Op: "case", Mode: "snake"}, ""},
+
+		{"prefix", Step{}, "needs text"},
+		{"insert:3", Step{}, "needs pos and text"},
+		{"remove:x:4", Step{}, "invalid pos 'x'"},
+		{"remove:0:four", Step{}, "invalid count 'four'"},
+		{"remove:0:0", Step{}, "count must be at least 1"},
+		{"replace::new", Step{}, "old text is required"},
+		{"regex:(:x", Step{}, "missing closing )"},
+		{"case:shout", Step{}, "shout"},
+		{"rename:x", Step{}, "unknown operation"},
+		{":x", Step{}, "unknown operation"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseStep(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseStep(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseStep(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
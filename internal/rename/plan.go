@@ -0,0 +1,714 @@
+package rename
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// ConflictPolicy decides what happens to a rename whose target is already taken
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves conflicting files untouched
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictAbort refuses to change anything if any rename conflicts
+	ConflictAbort ConflictPolicy = "abort"
+	// ConflictSuffix appends " (1)", " (2)", ... to the new name until it is free
+	ConflictSuffix ConflictPolicy = "suffix"
+	// ConflictOverwrite replaces whatever is at the target
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// ParseConflictPolicy converts a flag value into a ConflictPolicy
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(s)); p {
+	case ConflictSkip, ConflictAbort, ConflictSuffix, ConflictOverwrite:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid conflict policy '%s' (expected skip, abort, suffix or overwrite)", s)
+}
+
+// Status is the state of a single rename in a plan
+type Status int
+
+const (
+	StatusPending Status = iota
+	StatusSkipped
+	StatusApplied
+	StatusFailed
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSkipped:
+		return "skipped"
+	case StatusApplied:
+		return "applied"
+	case StatusFailed:
+		return "failed"
+	}
+	return "pending"
+}
+
+// Rename is a single planned move from OldPath to NewPath. An empty NewPath
+// marks a deletion.
+type Rename struct {
+	OldPath string
+	NewPath string
+	IsDir   bool
+	// Label identifies where the rename came from, e.g. a row of a mapping
+	// file; it is shown in the preview when set
+	Label string
+
+	// Conflict explains why NewPath is not available, empty when it is
+	Conflict string
+	// Note carries informational remarks for the preview (chains, suffixes)
+	Note string
+	// Overwrite allows the rename to replace an existing target
+	Overwrite bool
+	// SidecarOf is the rename this sidecar file follows, nil otherwise
+	SidecarOf *Rename
+
+	Status Status
+	Err    error
+
+	// parked is the temporary name the entry was moved to while breaking a
+	// rename cycle
+	parked string
+}
+
+// source is where the entry currently is on disk
+func (r *Rename) source() string {
+	if r.parked != "" {
+		return r.parked
+	}
+	return r.OldPath
+}
+
+// Plan holds every rename of a run so that collisions can be found and
+// resolved before anything on disk changes
+type Plan struct {
+	Root    string
+	Renames []*Rename
+	// CreateDirs creates missing parent directories of new paths, for
+	// renames that move entries between directories
+	CreateDirs bool
+	// RemoveEmptyDirs removes directories under Root that are left empty
+	// once their entries have been moved away
+	RemoveEmptyDirs bool
+
+	bySource map[string]*Rename
+}
+
+// NewPlan creates an empty plan; paths are shown relative to root
+func NewPlan(root string) *Plan {
+	return &Plan{
+		Root:     root,
+		bySource: make(map[string]*Rename),
+	}
+}
+
+// Add queues a rename and returns it. Renames that do not change the path
+// are ignored and return nil.
+func (p *Plan) Add(oldPath, newPath string, isDir bool) (*Rename, error) {
+	if oldPath == newPath {
+		return nil, nil
+	}
+	if _, exists := p.bySource[oldPath]; exists {
+		return nil, fmt.Errorf("%s is renamed more than once", p.rel(oldPath))
+	}
+
+	r := &Rename{OldPath: oldPath, NewPath: newPath, IsDir: isDir}
+	p.Renames = append(p.Renames, r)
+	p.bySource[oldPath] = r
+	return r, nil
+}
+
+// Remove queues the deletion of path. Renames onto path wait until it is gone.
+func (p *Plan) Remove(path string, isDir bool) (*Rename, error) {
+	if _, exists := p.bySource[path]; exists {
+		return nil, fmt.Errorf("%s is renamed more than once", p.rel(path))
+	}
+
+	r := &Rename{OldPath: path, IsDir: isDir}
+	p.Renames = append(p.Renames, r)
+	p.bySource[path] = r
+	return r, nil
+}
+
+// Skip records a rename that will not be applied, so that it still shows
+// up in the preview and report with the reason
+func (p *Plan) Skip(oldPath, newPath string, isDir bool, reason string) *Rename {
+	r := &Rename{OldPath: oldPath, NewPath: newPath, IsDir: isDir, Status: StatusSkipped, Note: reason}
+	p.Renames = append(p.Renames, r)
+	return r
+}
+
+// Count returns how many renames are in the given state
+func (p *Plan) Count(status Status) int {
+	count := 0
+	for _, r := range p.Renames {
+		if r.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Pending returns the renames that are still going to be applied
+func (p *Plan) Pending() []*Rename {
+	var pending []*Rename
+	for _, r := range p.Renames {
+		if r.Status == StatusPending {
+			pending = append(pending, r)
+		}
+	}
+	return pending
+}
+
+// Conflicts runs collision detection and returns the pending renames that
+// cannot be applied as planned
+func (p *Plan) Conflicts() []*Rename {
+	p.detect()
+
+	var conflicts []*Rename
+	for _, r := range p.Pending() {
+		if r.Conflict != "" {
+			conflicts = append(conflicts, r)
+		}
+	}
+	return conflicts
+}
+
+// Resolve applies the conflict policy until no conflicts remain. With
+// ConflictAbort it returns an error describing every conflict instead.
+func (p *Plan) Resolve(policy ConflictPolicy) error {
+	// Every pass either removes a conflict or skips a rename, so the loop is
+	// bounded by the number of renames; the extra pass confirms a clean plan.
+	for pass := 0; pass <= len(p.Renames); pass++ {
+		conflicts := p.Conflicts()
+		if len(conflicts) == 0 {
+			return nil
+		}
+
+		switch policy {
+		case ConflictAbort:
+			lines := make([]string, 0, len(conflicts))
+			for _, r := range conflicts {
+				lines = append(lines, fmt.Sprintf("  %s -> %s: %s", p.rel(r.OldPath), p.rel(r.NewPath), r.Conflict))
+			}
+			return fmt.Errorf("%d rename(s) conflict, nothing was changed:\n%s", len(conflicts), strings.Join(lines, "\n"))
+
+		case ConflictSkip:
+			for _, r := range conflicts {
+				r.Status = StatusSkipped
+				r.Note = r.Conflict
+			}
+
+		case ConflictSuffix:
+			taken := p.targets()
+			for _, r := range conflicts {
+				newPath, err := freeSuffixedPath(r.NewPath, r.IsDir, taken)
+				if err != nil {
+					r.Status = StatusSkipped
+					r.Note = err.Error()
+					continue
+				}
+				r.Note = fmt.Sprintf("suffixed (%s)", r.Conflict)
+				r.NewPath = newPath
+				taken[newPath] = true
+			}
+
+		case ConflictOverwrite:
+			for _, r := range conflicts {
+				if r.Overwrite {
+					// Overwriting cannot help, e.g. inside a cycle
+					r.Status = StatusSkipped
+					r.Note = r.Conflict
+					continue
+				}
+				r.Overwrite = true
+			}
+
+		default:
+			return fmt.Errorf("unknown conflict policy '%s'", policy)
+		}
+	}
+
+	return fmt.Errorf("could not resolve rename conflicts")
+}
+
+// detect recomputes the Conflict and Note of every pending rename
+func (p *Plan) detect() {
+	pending := p.Pending()
+
+	sources := make(map[string]*Rename, len(pending))
+	for _, r := range pending {
+		sources[r.OldPath] = r
+		r.Conflict = ""
+	}
+
+	// Many-to-one: the first rename in plan order keeps the target
+	first := make(map[string]*Rename, len(pending))
+	for _, r := range pending {
+		if r.NewPath == "" {
+			continue
+		}
+		if winner, exists := first[r.NewPath]; exists {
+			if !r.Overwrite {
+				r.Conflict = fmt.Sprintf("same target as %s", p.rel(winner.OldPath))
+			}
+			continue
+		}
+		first[r.NewPath] = r
+	}
+
+	for _, r := range pending {
+		if r.Conflict != "" || r.NewPath == "" {
+			continue
+		}
+
+		// Chain: the target is moved away earlier in the same run
+		if blocker, exists := sources[r.NewPath]; exists {
+			r.Note = fmt.Sprintf("after %s is renamed", p.rel(blocker.OldPath))
+			continue
+		}
+
+		if _, err := os.Lstat(r.NewPath); err != nil {
+			continue
+		}
+
+		// Same entry under another spelling, e.g. a case-only change on a
+		// case-insensitive filesystem
+		if isSameEntry(r.OldPath, r.NewPath) {
+			continue
+		}
+
+		if !r.Overwrite {
+			r.Conflict = "target already exists"
+		}
+	}
+
+	// Cycles are not conflicts: one member is moved through a temporary
+	// name when the plan is applied
+	_, cycles := p.order()
+	for _, cycle := range cycles {
+		for _, r := range cycle {
+			r.Note = "rename cycle, moved through a temporary name"
+		}
+	}
+}
+
+// order sorts the pending renames so that every target is vacated before
+// something else is moved onto it. Renames caught in a cycle are returned
+// separately, one slice per cycle; renames waiting on a cycle are in neither.
+func (p *Plan) order() ([]*Rename, [][]*Rename) {
+	pending := p.Pending()
+
+	sources := make(map[string]*Rename, len(pending))
+	for _, r := range pending {
+		sources[r.source()] = r
+	}
+
+	// dependents[x] lists the renames that must wait until x has moved
+	dependents := make(map[*Rename][]*Rename)
+	waiting := make(map[*Rename]int)
+	for _, r := range pending {
+		if blocker, exists := sources[r.NewPath]; exists && blocker != r {
+			dependents[blocker] = append(dependents[blocker], r)
+			waiting[r]++
+		}
+
+		// A directory is renamed only after everything inside it, so that
+		// the paths planned for its contents stay valid (deepest first)
+		for parent := filepath.Dir(r.OldPath); parent != filepath.Dir(parent); parent = filepath.Dir(parent) {
+			if dir, exists := sources[parent]; exists && dir.IsDir {
+				dependents[r] = append(dependents[r], dir)
+				waiting[dir]++
+			}
+		}
+	}
+
+	var ready, ordered []*Rename
+	for _, r := range pending {
+		if waiting[r] == 0 {
+			ready = append(ready, r)
+		}
+	}
+	for len(ready) > 0 {
+		r := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, r)
+		for _, d := range dependents[r] {
+			waiting[d]--
+			if waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(ordered) == len(pending) {
+		return ordered, nil
+	}
+
+	// Whatever is left waits on a cycle; collect the members of each cycle
+	// itself, the rest resolve once the cycle is broken
+	seen := make(map[*Rename]bool, len(pending))
+	for _, r := range ordered {
+		seen[r] = true
+	}
+	var cycles [][]*Rename
+	for _, r := range pending {
+		if seen[r] || !leadsBackTo(r, sources) {
+			continue
+		}
+		var cycle []*Rename
+		for cur := r; !seen[cur]; cur = sources[cur.NewPath] {
+			seen[cur] = true
+			cycle = append(cycle, cur)
+		}
+		cycles = append(cycles, cycle)
+	}
+	return ordered, cycles
+}
+
+// leadsBackTo reports whether following targets from r returns to r
+func leadsBackTo(r *Rename, sources map[string]*Rename) bool {
+	seen := make(map[*Rename]bool)
+	for cur := sources[r.NewPath]; cur != nil && !seen[cur]; cur = sources[cur.NewPath] {
+		if cur == r {
+			return true
+		}
+		seen[cur] = true
+	}
+	return false
+}
+
+// targets returns every path that is occupied after the plan is applied
+// as it stands, apart from what is already on disk
+func (p *Plan) targets() map[string]bool {
+	taken := make(map[string]bool)
+	for _, r := range p.Pending() {
+		if r.NewPath != "" {
+			taken[r.NewPath] = true
+		}
+	}
+	for _, r := range p.Renames {
+		if r.Status != StatusPending {
+			taken[r.OldPath] = true
+		}
+	}
+	return taken
+}
+
+// freeSuffixedPath finds "name (n).ext" next to path that is neither on disk
+// nor planned as a target
+func freeSuffixedPath(path string, isDir bool, taken map[string]bool) (string, error) {
+	dir := filepath.Dir(path)
+	stem, ext := splitName(filepath.Base(path), isDir)
+
+	for n := 1; n < 10000; n++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, n, ext))
+		if taken[candidate] {
+			continue
+		}
+		if _, err := os.Lstat(candidate); err == nil {
+			continue
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("no free name found for %s", path)
+}
+
+// splitName splits a name into stem and extension (including the dot).
+// Directories and dotfiles such as ".bashrc" have no extension.
+func splitName(name string, isDir bool) (string, string) {
+	if isDir {
+		return name, ""
+	}
+	ext := filepath.Ext(name)
+	if ext == name {
+		return name, ""
+	}
+	return strings.TrimSuffix(name, ext), ext
+}
+
+// Apply performs every pending rename in dependency order. Cycles such as
+// a swap are broken by parking one member under a temporary name. It keeps
+// going after a failure and returns the number of renames that succeeded.
+func (p *Plan) Apply() (int, error) {
+	count := 0
+	var errors []string
+
+	fail := func(r *Rename, err error) {
+		r.Status = StatusFailed
+		r.Err = err
+		if r.NewPath == "" {
+			errors = append(errors, fmt.Sprintf("failed to delete %s: %v", r.OldPath, err))
+			return
+		}
+		errors = append(errors, fmt.Sprintf("failed to rename %s to %s: %v", r.OldPath, r.NewPath, err))
+	}
+
+	for {
+		ordered, cycles := p.order()
+		if len(ordered) == 0 && len(cycles) == 0 {
+			break
+		}
+
+		for _, r := range ordered {
+			if err := p.applyOne(r); err != nil {
+				fail(r, err)
+				continue
+			}
+
+			r.Status = StatusApplied
+			if r.NewPath == "" {
+				fmt.Printf("Deleted: %s\n", p.rel(r.OldPath))
+			} else {
+				fmt.Printf("Renamed: %s -> %s\n", p.rel(r.OldPath), p.rel(r.NewPath))
+			}
+			count++
+		}
+
+		// Moving one member of each cycle out of the way turns the rest of
+		// the cycle into a chain for the next round
+		for _, cycle := range cycles {
+			r := cycle[0]
+			tmpPath, err := tempPath(r.OldPath)
+			if err == nil {
+				err = os.Rename(r.OldPath, tmpPath)
+			}
+			if err != nil {
+				fail(r, err)
+				continue
+			}
+			r.parked = tmpPath
+		}
+	}
+
+	// Whatever is still pending waits on something that never moves, e.g. a
+	// cycle through a directory and an entry inside it
+	for _, r := range p.Pending() {
+		if r.parked != "" {
+			fail(r, fmt.Errorf("unresolvable dependency (entry left at %s)", r.parked))
+			continue
+		}
+		fail(r, fmt.Errorf("unresolvable dependency"))
+	}
+
+	if p.RemoveEmptyDirs {
+		for _, err := range p.removeEmptyDirs() {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	if len(errors) > 0 {
+		return count, fmt.Errorf("some files could not be renamed:\n%s", strings.Join(errors, "\n"))
+	}
+
+	return count, nil
+}
+
+// applyOne renames a single entry, re-checking the target right before the
+// move so a failed rename earlier in a chain never leads to an overwrite
+func (p *Plan) applyOne(r *Rename) error {
+	source := r.source()
+
+	if r.NewPath == "" {
+		return os.Remove(source)
+	}
+
+	if _, err := os.Lstat(r.NewPath); err == nil {
+		if isSameEntry(source, r.NewPath) {
+			// A direct rename is a no-op or an error on filesystems that
+			// ignore case or normalization
+			return renameViaTemp(source, r.NewPath)
+		}
+		if !r.Overwrite {
+			if r.parked != "" {
+				return fmt.Errorf("target already exists (entry left at %s)", r.parked)
+			}
+			return fmt.Errorf("target already exists")
+		}
+	}
+
+	if p.CreateDirs {
+		if err := os.MkdirAll(filepath.Dir(r.NewPath), 0755); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(source, r.NewPath)
+}
+
+// removeEmptyDirs removes the directories applied renames moved entries out
+// of, and their parents, when nothing is left in them. Root itself is kept.
+func (p *Plan) removeEmptyDirs() []error {
+	candidates := make(map[string]bool)
+	for _, r := range p.Renames {
+		if r.Status != StatusApplied {
+			continue
+		}
+		for dir := filepath.Dir(r.OldPath); insideDir(p.Root, dir); dir = filepath.Dir(dir) {
+			candidates[dir] = true
+		}
+	}
+
+	// Deepest first, so a parent is checked after its emptied children
+	dirs := make([]string, 0, len(candidates))
+	for dir := range candidates {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+
+	var errs []error
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := os.Remove(dir); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove empty directory %s: %v", dir, err))
+			continue
+		}
+		fmt.Printf("Removed empty directory: %s\n", p.rel(dir))
+	}
+	return errs
+}
+
+// isSameEntry reports whether newPath resolves to the very entry at oldPath,
+// as it does for case-only or normalization-only changes on filesystems that
+// ignore them. Hard links are separate entries and do not count.
+func isSameEntry(oldPath, newPath string) bool {
+	oldInfo, err := os.Lstat(oldPath)
+	if err != nil {
+		return false
+	}
+	newInfo, err := os.Lstat(newPath)
+	if err != nil || !os.SameFile(oldInfo, newInfo) {
+		return false
+	}
+
+	newName := filepath.Base(newPath)
+	if newName == filepath.Base(oldPath) {
+		return true
+	}
+
+	// A hard link shows up in the directory under exactly the new name
+	entries, err := os.ReadDir(filepath.Dir(newPath))
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.Name() == newName {
+			return false
+		}
+	}
+	return true
+}
+
+// renameViaTemp moves oldPath to newPath through a temporary name in the
+// same directory
+func renameViaTemp(oldPath, newPath string) error {
+	tmpPath, err := tempPath(oldPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		// Put the entry back so it is not left under the temporary name
+		if restoreErr := os.Rename(tmpPath, oldPath); restoreErr != nil {
+			return fmt.Errorf("%v (entry left at %s)", err, tmpPath)
+		}
+		return err
+	}
+	return nil
+}
+
+// tempPath returns an unused name next to path
+func tempPath(path string) (string, error) {
+	dir := filepath.Dir(path)
+	for n := 0; n < 1000; n++ {
+		candidate := filepath.Join(dir, fmt.Sprintf(".filekit-tmp-%d-%d", os.Getpid(), n))
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free temporary name in %s", dir)
+}
+
+// Print writes the plan as a preview table
+func (p *Plan) Print() {
+	if len(p.Renames) == 0 {
+		fmt.Println("Nothing to rename")
+		return
+	}
+
+	labeled := false
+	for _, r := range p.Renames {
+		if r.Label != "" {
+			labeled = true
+			break
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if labeled {
+		fmt.Fprint(w, "ROW\t")
+	}
+	fmt.Fprintln(w, "ACTION\tFROM\tTO\tNOTE")
+	for _, r := range p.Renames {
+		if labeled {
+			fmt.Fprintf(w, "%s\t", r.Label)
+		}
+		to := p.rel(r.NewPath)
+		if r.NewPath == "" {
+			to = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.action(), p.rel(r.OldPath), to, r.note())
+	}
+	w.Flush()
+}
+
+// action describes what will happen (or happened) to a rename in the preview
+func (r *Rename) action() string {
+	switch {
+	case r.Status != StatusPending:
+		return r.Status.String()
+	case r.Conflict != "":
+		return "conflict"
+	case r.NewPath == "":
+		return "delete"
+	case r.Overwrite:
+		return "overwrite"
+	}
+	return "rename"
+}
+
+func (r *Rename) note() string {
+	switch {
+	case r.Err != nil:
+		return r.Err.Error()
+	case r.Conflict != "" && r.Status == StatusPending:
+		return r.Conflict
+	}
+	return r.Note
+}
+
+// rel shortens path for display relative to the plan root
+func (p *Plan) rel(path string) string {
+	if p.Root == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(p.Root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
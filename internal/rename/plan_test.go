@@ -0,0 +1,207 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// makeTree creates files under a new temporary directory; a path ending in
+// a slash is created as a directory
+func makeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if strings.HasSuffix(path, "/") {
+			if err := os.MkdirAll(full, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// readTree returns the content of every file under root by slash path
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+type planMove struct {
+	from, to string
+	isDir    bool
+}
+
+func TestPlanApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		moves  []planMove
+		policy ConflictPolicy
+		// resolveErr and applyErr are substrings of the expected errors
+		resolveErr string
+		applyErr   string
+		applied    int
+		want       map[string]string
+	}{
+		{
+			name:    "chain",
+			files:   map[string]string{"a": "A", "b": "B"},
+			moves:   []planMove{{"a", "b", false}, {"b", "c", false}},
+			policy:  ConflictAbort,
+			applied: 2,
+			want:    map[string]string{"b": "A", "c": "B"},
+		},
+		{
+			name:    "swap",
+			files:   map[string]string{"a": "A", "b": "B"},
+			moves:   []planMove{{"a", "b", false}, {"b", "a", false}},
+			policy:  ConflictAbort,
+			applied: 2,
+			want:    map[string]string{"a": "B", "b": "A"},
+		},
+		{
+			name:    "three-way cycle",
+			files:   map[string]string{"a": "A", "b": "B", "c": "C"},
+			moves:   []planMove{{"a", "b", false}, {"b", "c", false}, {"c", "a", false}},
+			policy:  ConflictAbort,
+			applied: 3,
+			want:    map[string]string{"a": "C", "b": "A", "c": "B"},
+		},
+		{
+			name:    "directory and its contents",
+			files:   map[string]string{"d/x": "X"},
+			moves:   []planMove{{"d", "e", true}, {"d/x", "d/y", false}},
+			policy:  ConflictAbort,
+			applied: 2,
+			want:    map[string]string{"e/y": "X"},
+		},
+		{
+			name:       "existing target with abort",
+			files:      map[string]string{"a": "A", "b": "B"},
+			moves:      []planMove{{"a", "b", false}},
+			policy:     ConflictAbort,
+			resolveErr: "target already exists",
+			want:       map[string]string{"a": "A", "b": "B"},
+		},
+		{
+			name:   "existing target with skip",
+			files:  map[string]string{"a": "A", "b": "B", "c": "C"},
+			moves:  []planMove{{"a", "b", false}, {"c", "d", false}},
+			policy: ConflictSkip,
+			// Only c is renamed
+			applied: 1,
+			want:    map[string]string{"a": "A", "b": "B", "d": "C"},
+		},
+		{
+			name:    "existing target with suffix",
+			files:   map[string]string{"a.txt": "A", "b.txt": "B", "b (1).txt": "B1"},
+			moves:   []planMove{{"a.txt", "b.txt", false}},
+			policy:  ConflictSuffix,
+			applied: 1,
+			want:    map[string]string{"b.txt": "B", "b (1).txt": "B1", "b (2).txt": "A"},
+		},
+		{
+			name:    "existing target with overwrite",
+			files:   map[string]string{"a": "A", "b": "B"},
+			moves:   []planMove{{"a", "b", false}},
+			policy:  ConflictOverwrite,
+			applied: 1,
+			want:    map[string]string{"b": "A"},
+		},
+		{
+			name:    "same target with suffix",
+			files:   map[string]string{"a.txt": "A", "b.txt": "B"},
+			moves:   []planMove{{"a.txt", "x.txt", false}, {"b.txt", "x.txt", false}},
+			policy:  ConflictSuffix,
+			applied: 2,
+			want:    map[string]string{"x.txt": "A", "x (1).txt": "B"},
+		},
+		{
+			name:       "same target with abort",
+			files:      map[string]string{"a": "A", "b": "B"},
+			moves:      []planMove{{"a", "x", false}, {"b", "x", false}},
+			policy:     ConflictAbort,
+			resolveErr: "same target as a",
+			want:       map[string]string{"a": "A", "b": "B"},
+		},
+		{
+			// d/f waits for d to move away, d waits for its contents
+			name:     "unresolvable dependency",
+			files:    map[string]string{"d/f": "F"},
+			moves:    []planMove{{"d", "e", true}, {"d/f", "d", false}},
+			policy:   ConflictAbort,
+			applyErr: "unresolvable dependency",
+			want:     map[string]string{"d/f": "F"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := makeTree(t, tt.files)
+			plan := NewPlan(root)
+			for _, m := range tt.moves {
+				from := filepath.Join(root, filepath.FromSlash(m.from))
+				to := filepath.Join(root, filepath.FromSlash(m.to))
+				if _, err := plan.Add(from, to, m.isDir); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := plan.Resolve(tt.policy)
+			if tt.resolveErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.resolveErr) {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.resolveErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Resolve() error = %v", err)
+				}
+				applied, err := plan.Apply()
+				if tt.applyErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.applyErr) {
+						t.Fatalf("Apply() error = %v, want %q", err, tt.applyErr)
+					}
+					if len(plan.Pending()) > 0 {
+						t.Errorf("%d rename(s) still pending after Apply()", len(plan.Pending()))
+					}
+				} else if err != nil {
+					t.Fatalf("Apply() error = %v", err)
+				}
+				if applied != tt.applied {
+					t.Errorf("Apply() = %d, want %d", applied, tt.applied)
+				}
+			}
+
+			if got := readTree(t, root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tree = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package rename
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultRefExts are the text files scanned for references: playlists, cue
+// sheets, Markdown and HTML
+var DefaultRefExts = []string{"m3u", "m3u8", "cue", "md", "markdown", "html", "htm"}
+
+// refSyntax is how a kind of text file writes references
+type refSyntax struct {
+	patterns []*regexp.Regexp
+	// urlEncoded references may use %20 escapes and carry #fragments
+	urlEncoded bool
+}
+
+var (
+	// Every line that is not a comment or directive is a path
+	playlistRefRe = regexp.MustCompile(`(?m)^[ \t]*([^#\r\n][^\r\n]*?)[ \t]*\r?$`)
+	// FILE "Track 01.wav" WAVE
+	cueRefRe = regexp.MustCompile(`(?mi)^[ \t]*FILE[ \t]+(?:"([^"\r\n]*)"|(\S+))`)
+	// [text](path "title"), ![alt](<path with spaces>)
+	markdownLinkRe = regexp.MustCompile(`\]\(\s*(<[^>\r\n]*>|[^)\s]+)`)
+	// [id]: path
+	markdownDefRe = regexp.MustCompile(`(?m)^ {0,3}\[[^\]\r\n]+\]:[ \t]*(<[^>\r\n]*>|\S+)`)
+	// src="..." href='...'
+	htmlAttrRe = regexp.MustCompile(`(?i)\b(?:src|href|poster|data)[ \t]*=[ \t]*(?:"([^"]*)"|'([^']*)')`)
+	// http:, mailto:, data: ... but not a Windows drive letter
+	urlSchemeRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]+:`)
+)
+
+var refSyntaxes = map[string]refSyntax{
+	"m3u":      {patterns: []*regexp.Regexp{playlistRefRe}},
+	"m3u8":     {patterns: []*regexp.Regexp{playlistRefRe}},
+	"cue":      {patterns: []*regexp.Regexp{cueRefRe}},
+	"md":       {patterns: []*regexp.Regexp{markdownLinkRe, markdownDefRe, htmlAttrRe}, urlEncoded: true},
+	"markdown": {patterns: []*regexp.Regexp{markdownLinkRe, markdownDefRe, htmlAttrRe}, urlEncoded: true},
+	"html":     {patterns: []*regexp.Regexp{htmlAttrRe}, urlEncoded: true},
+	"htm":      {patterns: []*regexp.Regexp{htmlAttrRe}, urlEncoded: true},
+}
+
+// ParseRefExts splits a comma separated list of reference file extensions
+// and checks that each is a format references can be updated in
+func ParseRefExts(s string) ([]string, error) {
+	var exts []string
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext == "" {
+			continue
+		}
+		if _, known := refSyntaxes[ext]; !known {
+			return nil, fmt.Errorf("cannot update references in '.%s' files (supported: %s)", ext, strings.Join(DefaultRefExts, ", "))
+		}
+		exts = append(exts, ext)
+	}
+	if len(exts) == 0 {
+		return nil, fmt.Errorf("empty reference file extension list")
+	}
+	return exts, nil
+}
+
+// refEdit replaces the bytes [start, end) of a file
+type refEdit struct {
+	start, end int
+	text       string
+}
+
+// UpdateReferences rewrites references to renamed entries in the text files
+// under the plan root whose extension is in exts. Relative references stay
+// relative to the referring file, absolute ones stay absolute, and URLs
+// are left alone. Applied renames are used, or pending ones in a dry run,
+// where the files are only reported. It returns the number of files edited.
+func (p *Plan) UpdateReferences(exts []string, dryRun bool) (int, error) {
+	moved := make(map[string]string)
+	for _, r := range p.Renames {
+		done := r.Status == StatusApplied || dryRun && r.Status == StatusPending
+		if done && r.NewPath != "" {
+			moved[r.OldPath] = r.NewPath
+		}
+	}
+	if len(moved) == 0 {
+		return 0, nil
+	}
+
+	wanted := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		wanted[strings.ToLower(ext)] = true
+	}
+
+	// In a dry run nothing has moved yet, so the files are still at their
+	// old paths and the old paths are what is walked
+	var files []string
+	err := filepath.WalkDir(p.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+		if d.Type().IsRegular() && wanted[ext] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan for references: %v", err)
+	}
+
+	edited := 0
+	var failures []string
+	for _, file := range files {
+		oldFile, newFile := file, file
+		if dryRun {
+			newFile = p.mapForward(file, moved)
+		} else {
+			oldFile = p.mapBack(file, moved)
+		}
+
+		count, err := p.updateFile(file, oldFile, newFile, moved, dryRun)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", p.rel(file), err))
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+
+		edited++
+		if dryRun {
+			fmt.Printf("Would update %d reference(s) in %s\n", count, p.rel(file))
+		} else {
+			fmt.Printf("Updated %d reference(s) in %s\n", count, p.rel(file))
+		}
+	}
+
+	if len(failures) > 0 {
+		return edited, fmt.Errorf("some references could not be updated:\n%s", strings.Join(failures, "\n"))
+	}
+	return edited, nil
+}
+
+// updateFile rewrites the references in file, which was at oldFile before
+// the renames and is at newFile after them
+func (p *Plan) updateFile(file, oldFile, newFile string, moved map[string]string, dryRun bool) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	content := string(data)
+	syntax := refSyntaxes[strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))]
+
+	var edits []refEdit
+	seen := make(map[int]bool)
+	for _, re := range syntax.patterns {
+		for _, m := range re.FindAllStringSubmatchIndex(content, -1) {
+			// The reference is whichever alternative group matched
+			start, end := -1, -1
+			for g := 2; g+1 < len(m); g += 2 {
+				if m[g] >= 0 {
+					start, end = m[g], m[g+1]
+					break
+				}
+			}
+			if start < 0 || seen[start] {
+				continue
+			}
+			seen[start] = true
+
+			newRef, changed := p.rewriteRef(content[start:end], filepath.Dir(oldFile), filepath.Dir(newFile), moved, syntax.urlEncoded)
+			if changed {
+				edits = append(edits, refEdit{start: start, end: end, text: newRef})
+			}
+		}
+	}
+	if len(edits) == 0 || dryRun {
+		return len(edits), nil
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		content = content[:e.start] + e.text + content[e.end:]
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(file, []byte(content), info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	return len(edits), nil
+}
+
+// rewriteRef returns ref pointing at the new location of its target, and
+// whether it changed. oldDir is where the referring file was when ref was
+// written, newDir where it is now.
+func (p *Plan) rewriteRef(ref, oldDir, newDir string, moved map[string]string, urlEncoded bool) (string, bool) {
+	angled := strings.HasPrefix(ref, "<") && strings.HasSuffix(ref, ">")
+	raw := strings.TrimSuffix(strings.TrimPrefix(ref, "<"), ">")
+	if raw == "" || strings.HasPrefix(raw, "#") || urlSchemeRe.MatchString(raw) {
+		return ref, false
+	}
+
+	path, suffix := raw, ""
+	encoded := false
+	if urlEncoded {
+		if i := strings.IndexAny(path, "?#"); i >= 0 {
+			path, suffix = path[:i], path[i:]
+		}
+		if decoded, err := url.PathUnescape(path); err == nil && decoded != path {
+			path, encoded = decoded, true
+		}
+	}
+
+	backslashes := strings.Contains(path, `\`) && !strings.Contains(path, "/")
+	native := filepath.FromSlash(strings.ReplaceAll(path, `\`, "/"))
+
+	target := native
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(oldDir, target)
+	}
+	target = filepath.Clean(target)
+
+	newTarget := p.mapForward(target, moved)
+	if newTarget == target && oldDir == newDir {
+		return ref, false
+	}
+
+	newPath := newTarget
+	if !filepath.IsAbs(native) {
+		rel, err := filepath.Rel(newDir, newTarget)
+		if err != nil {
+			return ref, false
+		}
+		newPath = rel
+		if strings.HasPrefix(path, "./") || strings.HasPrefix(path, `.\`) {
+			newPath = "." + string(filepath.Separator) + newPath
+		}
+	}
+
+	newPath = filepath.ToSlash(newPath)
+	if backslashes {
+		newPath = strings.ReplaceAll(newPath, "/", `\`)
+	}
+	if newPath == filepath.ToSlash(path) || backslashes && newPath == path {
+		return ref, false
+	}
+
+	// Keep links working: Markdown destinations cannot contain spaces
+	// unless they are escaped or wrapped in <>
+	if encoded || urlEncoded && !angled && strings.ContainsAny(newPath, " ()<>") {
+		newPath = (&url.URL{Path: newPath}).EscapedPath()
+	}
+
+	newRef := newPath + suffix
+	if angled {
+		newRef = "<" + newRef + ">"
+	}
+	return newRef, true
+}
+
+// mapForward returns where path is after the renames: the entry itself may
+// have been renamed, then any of its parent directories
+func (p *Plan) mapForward(path string, moved map[string]string) string {
+	if newPath, exists := moved[path]; exists {
+		path = newPath
+	}
+	for dir := filepath.Dir(path); insideDir(p.Root, dir); {
+		if newDir, exists := moved[dir]; exists {
+			path = newDir + path[len(dir):]
+			dir = filepath.Dir(newDir)
+			continue
+		}
+		dir = filepath.Dir(dir)
+	}
+	return path
+}
+
+// mapBack returns where path was before the renames, undoing directory
+// renames from the root down and then the entry's own rename
+func (p *Plan) mapBack(path string, moved map[string]string) string {
+	rel, err := filepath.Rel(p.Root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	back := make(map[string]string, len(moved))
+	for oldPath, newPath := range moved {
+		back[newPath] = oldPath
+	}
+
+	current := p.Root
+	for _, element := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, element)
+		if oldPath, exists := back[current]; exists {
+			current = oldPath
+		}
+	}
+	return current
+}
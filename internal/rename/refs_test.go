@@ -0,0 +1,164 @@
+package rename
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateReferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		moves  []planMove
+		dryRun bool
+		want   map[string]string
+	}{
+		{
+			name:  "renamed target",
+			files: map[string]string{"list.m3u": "#EXTM3U\n#EXTINF:1,Song\nmusic/a.mp3\nmusic/b.mp3\n", "music/a.mp3": "A", "music/b.mp3": "B"},
+			moves: []planMove{{"music/a.mp3", "music/01 a.mp3", false}},
+			want:  map[string]string{"list.m3u": "#EXTM3U\n#EXTINF:1,Song\nmusic/01 a.mp3\nmusic/b.mp3\n", "music/01 a.mp3": "A", "music/b.mp3": "B"},
+		},
+		{
+			name:  "renamed directory",
+			files: map[string]string{"album.cue": "FILE \"disc/Track 01.wav\" WAVE\n", "disc/Track 01.wav": "W"},
+			moves: []planMove{{"disc", "CD1", true}},
+			want:  map[string]string{"album.cue": "FILE \"CD1/Track 01.wav\" WAVE\n", "CD1/Track 01.wav": "W"},
+		},
+		{
+			name:  "moved referring file",
+			files: map[string]string{"notes.md": "![cover](img/cover.jpg) [web](https://example.com/img/cover.jpg) [top](#top)\n", "img/cover.jpg": "C"},
+			moves: []planMove{{"notes.md", "docs/notes.md", false}},
+			want:  map[string]string{"docs/notes.md": "![cover](../img/cover.jpg) [web](https://example.com/img/cover.jpg) [top](#top)\n", "img/cover.jpg": "C"},
+		},
+		{
+			name:  "swap",
+			files: map[string]string{"list.m3u8": "a.mp3\nb.mp3\n", "a.mp3": "A", "b.mp3": "B"},
+			moves: []planMove{{"a.mp3", "b.mp3", false}, {"b.mp3", "a.mp3", false}},
+			want:  map[string]string{"list.m3u8": "b.mp3\na.mp3\n", "a.mp3": "B", "b.mp3": "A"},
+		},
+		{
+			name: "escaped and angled Markdown links",
+			files: map[string]string{
+				"index.md":      "[a](My%20Song.mp3#t=10) [b](<My Song.mp3>)\n[c]: ./My%20Song.mp3\n<a href=\"My%20Song.mp3\">d</a>\n",
+				"My Song.mp3":   "S",
+				"plain_name.md": "[e](other.mp3)\n",
+			},
+			moves: []planMove{{"My Song.mp3", "Your Song (live).mp3", false}},
+			want: map[string]string{
+				"index.md":             "[a](Your%20Song%20%28live%29.mp3#t=10) [b](<Your Song (live).mp3>)\n[c]: ./Your%20Song%20%28live%29.mp3\n<a href=\"Your%20Song%20%28live%29.mp3\">d</a>\n",
+				"Your Song (live).mp3": "S",
+				"plain_name.md":        "[e](other.mp3)\n",
+			},
+		},
+		{
+			name:  "backslash playlist paths",
+			files: map[string]string{"list.m3u": "music\\a.mp3\r\nmusic\\b.mp3\r\n", "music/a.mp3": "A", "music/b.mp3": "B"},
+			moves: []planMove{{"music", "Music", true}},
+			want:  map[string]string{"list.m3u": "Music\\a.mp3\r\nMusic\\b.mp3\r\n", "Music/a.mp3": "A", "Music/b.mp3": "B"},
+		},
+		{
+			name:   "dry run",
+			files:  map[string]string{"list.m3u": "a.mp3\n", "a.mp3": "A"},
+			moves:  []planMove{{"a.mp3", "b.mp3", false}},
+			dryRun: true,
+			want:   map[string]string{"list.m3u": "a.mp3\n", "a.mp3": "A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := makeTree(t, tt.files)
+			plan := NewPlan(root)
+			plan.CreateDirs = true
+			for _, m := range tt.moves {
+				from, to := filepath.Join(root, filepath.FromSlash(m.from)), filepath.Join(root, filepath.FromSlash(m.to))
+				if _, err := plan.Add(from, to, m.isDir); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := ExecOptions{OnConflict: ConflictAbort, RefExts: DefaultRefExts, DryRun: tt.dryRun}
+			if _, err := Execute(plan, opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTree(t, root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tree = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapForwardAndBack(t *testing.T) {
+	root := filepath.FromSlash("/root")
+	abs := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+	plan := NewPlan(root)
+	moved := map[string]string{
+		abs("a"):       abs("A"),
+		abs("a/b"):     abs("a/B"),
+		abs("a/b/f"):   abs("a/b/g"),
+		abs("x.txt"):   abs("sub/y.txt"),
+		abs("sub2/z"):  abs("z"),
+		abs("outside"): abs("a/inside"),
+	}
+
+	tests := []struct {
+		before, after string
+	}{
+		{"a", "A"},
+		{"a/b", "A/B"},
+		{"a/b/f", "A/B/g"},
+		{"a/b/other", "A/B/other"},
+		{"a/c", "A/c"},
+		{"x.txt", "sub/y.txt"},
+		{"sub2/z", "z"},
+		{"outside", "A/inside"},
+		{"untouched/file", "untouched/file"},
+	}
+
+	for _, tt := range tests {
+		if got := plan.mapForward(abs(tt.before), moved); got != abs(tt.after) {
+			t.Errorf("mapForward(%s) = %s, want %s", tt.before, got, abs(tt.after))
+		}
+		if got := plan.mapBack(abs(tt.after), moved); got != abs(tt.before) {
+			t.Errorf("mapBack(%s) = %s, want %s", tt.after, got, abs(tt.before))
+		}
+	}
+}
+
+func TestRewriteRef(t *testing.T) {
+	root := filepath.FromSlash("/root")
+	abs := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+	plan := NewPlan(root)
+	moved := map[string]string{abs("music/a.mp3"): abs("music/b.mp3")}
+
+	tests := []struct {
+		ref            string
+		oldDir, newDir string
+		urlEncoded     bool
+		want           string
+		changed        bool
+	}{
+		{"music/a.mp3", "", "", false, "music/b.mp3", true},
+		{"./music/a.mp3", "", "", false, "./music/b.mp3", true},
+		{`music\a.mp3`, "", "", false, `music\b.mp3`, true},
+		{"a.mp3", "music", "music", false, "b.mp3", true},
+		{abs("music/a.mp3"), "docs", "docs", false, abs("music/b.mp3"), true},
+		{"music/a.mp3", "", "docs", false, "../music/b.mp3", true},
+		{"music/c.mp3", "", "docs", false, "../music/c.mp3", true},
+		{"music/c.mp3", "", "", false, "music/c.mp3", false},
+		{"music%2Fa.mp3?x=1", "", "", true, "music/b.mp3?x=1", true},
+		{"<music/a.mp3>", "", "", true, "<music/b.mp3>", true},
+		{"http://example.com/music/a.mp3", "", "", true, "http://example.com/music/a.mp3", false},
+		{"#section", "", "", true, "#section", false},
+		{"", "", "", true, "", false},
+	}
+
+	for _, tt := range tests {
+		got, changed := plan.rewriteRef(tt.ref, abs(tt.oldDir), abs(tt.newDir), moved, tt.urlEncoded)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("rewriteRef(%q, %q, %q) = %q, %v; want %q, %v", tt.ref, tt.oldDir, tt.newDir, got, changed, tt.want, tt.changed)
+		}
+	}
+}
@@ -1,51 +1,161 @@
 package rename
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
-// ReplaceInFilenames renames files in the given directory by replacing target string with replaceWith
-func ReplaceInFilenames(dir, target, replaceWith string) (int, error) {
-	count := 0
+// Options controls how ReplaceInFilenames matches and rewrites names
+type Options struct {
+	// Regex treats target as a Go regular expression and allows $1 / ${name}
+	// expansions in replaceWith
+	Regex bool
+	// IgnoreCase matches target case-insensitively
+	IgnoreCase bool
+	// Part limits the replacement to the stem, the extension or the full name;
+	// the zero value means the full name
+	Part NamePart
+	// Scope selects which files and directories are visited
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// ReplaceInFilenames renames files in the given directory by replacing target string with replaceWith.
+// Every rename is planned and checked for collisions before anything on disk changes;
+// directories are renamed after their contents, deepest first.
+// It returns the number of files renamed, or the number that would be renamed in a dry run.
+func ReplaceInFilenames(dir, target, replaceWith string, opts Options) (int, error) {
+	replace, err := newReplacer(target, replaceWith, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+
+	plan, err := PlanNameChanges(dir, entries, opts.Part, replace)
+	if err != nil {
+		return 0, err
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// ExecOptions controls how a plan is carried out
+type ExecOptions struct {
+	// DryRun prints the planned renames without touching the disk
+	DryRun bool
+	// OnConflict decides what happens when a new name is already taken;
+	// the zero value aborts
+	OnConflict ConflictPolicy
+	// Sidecars, when set, lists the extensions of sidecar files that follow
+	// their primary file to its new name (see Plan.AddSidecars)
+	Sidecars []string
+	// RefExts, when set, lists the extensions of text files (playlists,
+	// cue sheets, Markdown, HTML) whose references to renamed entries are
+	// updated (see Plan.UpdateReferences)
+	RefExts []string
+	// Confirm, when set, is shown the resolved plan (already printed) and
+	// must return true for it to be applied
+	Confirm func(plan *Plan) bool
+}
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// ErrCancelled is returned by Execute when Confirm declines the plan
+var ErrCancelled = errors.New("operation cancelled")
+
+// Execute resolves conflicts in plan and applies it. In a dry run the plan
+// is only printed and the number of renames that would happen is returned.
+func Execute(plan *Plan, opts ExecOptions) (int, error) {
+	policy := opts.OnConflict
+	if policy == "" {
+		policy = ConflictAbort
+	}
+
+	err := plan.Resolve(policy)
+	// Sidecars follow the names the primaries end up with, e.g. suffixed
+	if err == nil && len(opts.Sidecars) > 0 {
+		if err = plan.AddSidecars(opts.Sidecars); err == nil {
+			err = plan.Resolve(policy)
+		}
+	}
+	if opts.DryRun {
+		plan.Print()
+		if err == nil && len(opts.RefExts) > 0 {
+			_, err = plan.UpdateReferences(opts.RefExts, true)
 		}
+		return len(plan.Pending()), err
+	}
+	if err != nil {
+		return 0, err
+	}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
+	if opts.Confirm != nil && len(plan.Pending()) > 0 {
+		plan.Print()
+		if !opts.Confirm(plan) {
+			return 0, ErrCancelled
 		}
+	}
 
-		// Get the directory and filename
-		dirPath := filepath.Dir(path)
-		filename := info.Name()
+	count, err := plan.Apply()
+	// References to whatever was renamed are updated even when some
+	// renames failed
+	if len(opts.RefExts) > 0 {
+		if _, refErr := plan.UpdateReferences(opts.RefExts, false); refErr != nil && err == nil {
+			err = refErr
+		}
+	}
+	return count, err
+}
 
-		// Check if filename contains target string
-		if strings.Contains(filename, target) {
-			newFilename := strings.ReplaceAll(filename, target, replaceWith)
-			newPath := filepath.Join(dirPath, newFilename)
+// validName checks that a generated name can be used as a single path element
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("invalid new name %q", name)
+	}
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		return fmt.Errorf("new name %q contains a path separator", name)
+	}
+	return nil
+}
 
-			// Rename the file
-			err := os.Rename(path, newPath)
-			if err != nil {
-				return fmt.Errorf("failed to rename %s to %s: %v", path, newPath, err)
+// newReplacer builds the function that rewrites a single name. It reports
+// false when the name does not contain a match.
+func newReplacer(target, replaceWith string, opts Options) (func(string) (string, bool), error) {
+	if !opts.Regex && !opts.IgnoreCase {
+		return func(name string) (string, bool) {
+			if !strings.Contains(name, target) {
+				return name, false
 			}
+			return strings.ReplaceAll(name, target, replaceWith), true
+		}, nil
+	}
 
-			fmt.Printf("Renamed: %s -> %s\n", filename, newFilename)
-			count++
-		}
-
-		return nil
-	})
+	pattern := target
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(target)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
 
+	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return count, err
+		return nil, fmt.Errorf("invalid regular expression '%s': %v", target, err)
 	}
 
-	return count, nil
+	return func(name string) (string, bool) {
+		if !re.MatchString(name) {
+			return name, false
+		}
+		if opts.Regex {
+			return re.ReplaceAllString(name, replaceWith), true
+		}
+		return re.ReplaceAllLiteralString(name, replaceWith), true
+	}, nil
 }
@@ -0,0 +1,238 @@
+package rename
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// windowsIllegal are the characters Windows does not allow in names
+const windowsIllegal = `<>:"/\|?*`
+
+// reservedNames are the Windows device names, which are reserved with any
+// extension and in any case
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// asciiFallbacks spells out letters and punctuation that do not decompose
+// into an ASCII base letter
+var asciiFallbacks = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D",
+	'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "Th", 'ı': "i",
+	'‘': "'", '’': "'", '‚': "'", '“': `"`, '”': `"`, '„': `"`,
+	'–': "-", '—': "-", '…': "...", '×': "x", '€': "EUR", '£': "GBP",
+	'\u00a0': " ",
+}
+
+// DefaultMaxNameBytes is the name length limit of common filesystems
+const DefaultMaxNameBytes = 255
+
+// SanitizeOptions controls SanitizeNames
+type SanitizeOptions struct {
+	// Replacement is put in place of illegal and control characters; it may
+	// be empty to drop them
+	Replacement string
+	// ASCII transliterates names to ASCII, e.g. "Café" to "Cafe"
+	ASCII bool
+	// MaxBytes is the longest allowed name in bytes; 0 means
+	// DefaultMaxNameBytes
+	MaxBytes int
+	// Scope selects which files and directories are visited
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// Validate checks that the options can produce portable names
+func (o SanitizeOptions) Validate() error {
+	if strings.ContainsAny(o.Replacement, windowsIllegal) || strings.ContainsFunc(o.Replacement, unicode.IsControl) {
+		return fmt.Errorf("replacement %q is not allowed in names itself", o.Replacement)
+	}
+	if o.ASCII && !isASCII(o.Replacement) {
+		return fmt.Errorf("replacement %q is not ASCII", o.Replacement)
+	}
+	if o.MaxBytes < 0 {
+		return fmt.Errorf("invalid maximum name length %d", o.MaxBytes)
+	}
+	if o.MaxBytes > 0 && o.MaxBytes < 8 {
+		return fmt.Errorf("maximum name length %d is too short to keep names readable", o.MaxBytes)
+	}
+	return nil
+}
+
+// SanitizeNames renames entries under dir whose names are not portable to
+// Windows and other common filesystems. Each rename notes what was fixed.
+func SanitizeNames(dir string, opts SanitizeOptions) (int, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+	entries = skipInvalidUTF8(entries)
+
+	plan := NewPlan(dir)
+	for _, entry := range entries {
+		name := entry.Info.Name()
+		newName, fixes := SanitizeName(name, entry.Info.IsDir(), opts)
+		if newName == name {
+			continue
+		}
+		if err := validName(newName); err != nil {
+			return 0, fmt.Errorf("renaming %s: %v", entry.RelPath, err)
+		}
+
+		r, err := plan.Add(entry.Path, filepath.Join(filepath.Dir(entry.Path), newName), entry.Info.IsDir())
+		if err != nil {
+			return 0, err
+		}
+		if r != nil {
+			r.Note = strings.Join(fixes, ", ")
+		}
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// SanitizeName returns a portable version of name and a short description
+// of every fix applied. Names that are not valid UTF-8 are returned as they
+// are, since replacing their bytes would lose what fix-encoding can recover.
+func SanitizeName(name string, isDir bool, opts SanitizeOptions) (string, []string) {
+	if !utf8.ValidString(name) {
+		return name, nil
+	}
+
+	var fixes []string
+	fixed := func(fix string) {
+		fixes = append(fixes, fix)
+	}
+
+	if opts.ASCII && !isASCII(name) {
+		name = transliterate(name, opts.Replacement)
+		fixed("transliterated")
+	}
+
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		name = replaceRunes(name, unicode.IsControl, opts.Replacement)
+		fixed("control characters")
+	}
+
+	if strings.ContainsAny(name, windowsIllegal) {
+		name = replaceRunes(name, func(r rune) bool { return strings.ContainsRune(windowsIllegal, r) }, opts.Replacement)
+		fixed("illegal characters")
+	}
+
+	if trimmed := strings.TrimRight(name, ". "); trimmed != name {
+		name = trimmed
+		fixed("trailing dots or spaces")
+	}
+
+	if name == "" {
+		name = opts.Replacement
+		if name == "" || strings.Trim(name, ". ") == "" {
+			name = "_"
+		}
+	}
+
+	// "CON", "con.txt" and "Nul.tar.gz" are all reserved
+	base, rest, _ := strings.Cut(name, ".")
+	if reservedNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		suffix := opts.Replacement
+		if suffix == "" {
+			suffix = "_"
+		}
+		name = base + suffix
+		if rest != "" {
+			name += "." + rest
+		}
+		fixed("reserved name")
+	}
+
+	maxBytes := opts.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxNameBytes
+	}
+	if len(name) > maxBytes {
+		name = truncateName(name, isDir, maxBytes)
+		fixed("too long")
+	}
+
+	return name, fixes
+}
+
+// transliterate strips accents and spells out other letters in ASCII;
+// anything left is replaced with replacement
+func transliterate(s, replacement string) string {
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		stripped = s
+	}
+
+	var b strings.Builder
+	for _, r := range stripped {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case asciiFallbacks[r] != "":
+			b.WriteString(asciiFallbacks[r])
+		default:
+			b.WriteString(replacement)
+		}
+	}
+	return b.String()
+}
+
+// replaceRunes replaces every rune matching f with replacement
+func replaceRunes(s string, f func(rune) bool, replacement string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if f(r) {
+			b.WriteString(replacement)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// truncateName shortens name to at most maxBytes without splitting a
+// character, keeping the extension when there is room for it
+func truncateName(name string, isDir bool, maxBytes int) string {
+	stem, ext := splitName(name, isDir)
+	if len(ext) >= maxBytes/2 {
+		stem, ext = name, ""
+	}
+
+	limit := maxBytes - len(ext)
+	for limit > 0 && !utf8.RuneStart(stem[limit]) {
+		limit--
+	}
+	stem = strings.TrimRight(stem[:limit], ". ")
+	if stem == "" {
+		stem = "_"
+	}
+	return stem + ext
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
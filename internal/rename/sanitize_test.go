@@ -0,0 +1,48 @@
+package rename
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		in    string
+		opts  SanitizeOptions
+		want  string
+		fixes []string
+	}{
+		{"report.pdf", SanitizeOptions{Replacement: "_"}, "report.pdf", nil},
+		{`a<b>c:d?.txt`, SanitizeOptions{Replacement: "_"}, "a_b_c_d_.txt", []string{"illegal characters"}},
+		{"tab\there.txt", SanitizeOptions{Replacement: ""}, "tabhere.txt", []string{"control characters"}},
+		{"notes. ", SanitizeOptions{Replacement: "_"}, "notes", []string{"trailing dots or spaces"}},
+		{"con.txt", SanitizeOptions{Replacement: "_"}, "con_.txt", []string{"reserved name"}},
+		{"Café – Straße.txt", SanitizeOptions{Replacement: "_", ASCII: true}, "Cafe - Strasse.txt", []string{"transliterated"}},
+		{strings.Repeat("é", 10) + ".txt", SanitizeOptions{Replacement: "_", MaxBytes: 13}, "éééé.txt", []string{"too long"}},
+
+		// Names that are not UTF-8 are left for fix-encoding
+		{"caf\xe9.txt", SanitizeOptions{Replacement: "_"}, "caf\xe9.txt", nil},
+		{"caf\xe9?.txt", SanitizeOptions{Replacement: "_", ASCII: true}, "caf\xe9?.txt", nil},
+	}
+
+	for _, tt := range tests {
+		got, fixes := SanitizeName(tt.in, false, tt.opts)
+		if got != tt.want || !reflect.DeepEqual(fixes, tt.fixes) {
+			t.Errorf("SanitizeName(%q) = %q, %v; want %q, %v", tt.in, got, fixes, tt.want, tt.fixes)
+		}
+	}
+}
+
+func TestSanitizeNamesKeepsInvalidUTF8(t *testing.T) {
+	root := makeTree(t, map[string]string{"caf\xe9.txt": "x", "a?.txt": "y"})
+
+	if _, err := SanitizeNames(root, SanitizeOptions{Replacement: "_"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"caf\xe9.txt": "x", "a_.txt": "y"}
+	if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+package rename
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NamePart selects which part of a name a rename applies to
+type NamePart string
+
+const (
+	// PartFull is the whole name, extension included
+	PartFull NamePart = "full"
+	// PartStem is the name without its extension
+	PartStem NamePart = "stem"
+	// PartExt is the extension without the leading dot
+	PartExt NamePart = "ext"
+)
+
+// ParseNamePart converts a flag value into a NamePart
+func ParseNamePart(s string) (NamePart, error) {
+	switch p := NamePart(strings.ToLower(s)); p {
+	case PartFull, PartStem, PartExt:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid name part '%s' (expected stem, ext or full)", s)
+}
+
+// Scope selects which entries of a tree a rename visits
+type Scope struct {
+	// Dirs includes directories as well as files
+	Dirs bool
+	// DirsOnly includes directories and leaves files out
+	DirsOnly bool
+	// MaxDepth limits how deep the walk goes; entries directly inside the
+	// root are at depth 1 and 0 means unlimited
+	MaxDepth int
+	// Include keeps only entries matching at least one glob, if any are given
+	Include []string
+	// Exclude drops entries matching any glob; excluded directories are not
+	// descended into
+	Exclude []string
+	// SkipHidden ignores dotfiles and does not descend into dot-directories
+	SkipHidden bool
+}
+
+// Entry is a file or directory selected by a Scope
+type Entry struct {
+	Path    string
+	RelPath string
+	Info    os.FileInfo
+	Depth   int
+}
+
+// Validate checks the include and exclude globs
+func (s Scope) Validate() error {
+	for _, pattern := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Collect walks root and returns every entry selected by the scope, parents
+// before their children. The root itself is never included.
+func Collect(root string, scope Scope) ([]Entry, error) {
+	if err := scope.Validate(); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		depth := strings.Count(filepath.ToSlash(relPath), "/") + 1
+
+		if scope.SkipHidden && strings.HasPrefix(info.Name(), ".") {
+			return skipEntry(info)
+		}
+		if matchesAny(scope.Exclude, info.Name(), relPath) {
+			return skipEntry(info)
+		}
+
+		selected := true
+		if info.IsDir() {
+			selected = scope.Dirs || scope.DirsOnly
+		} else if scope.DirsOnly {
+			selected = false
+		}
+		if selected && len(scope.Include) > 0 && !matchesAny(scope.Include, info.Name(), relPath) {
+			selected = false
+		}
+
+		if selected {
+			entries = append(entries, Entry{
+				Path:    path,
+				RelPath: relPath,
+				Info:    info,
+				Depth:   depth,
+			})
+		}
+
+		// Do not look inside directories at the depth limit
+		if info.IsDir() && scope.MaxDepth > 0 && depth >= scope.MaxDepth {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// skipEntry leaves out an entry, and everything below it for directories
+func skipEntry(info os.FileInfo) error {
+	if info.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// matchesAny reports whether a glob matches the name, or the relative path
+// when the glob contains a slash
+func matchesAny(patterns []string, name, relPath string) bool {
+	for _, pattern := range patterns {
+		subject := name
+		if strings.Contains(pattern, "/") {
+			subject = filepath.ToSlash(relPath)
+		}
+		if matched, _ := filepath.Match(pattern, subject); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyToPart runs fn on the selected part of name and puts the name back
+// together. Directories have no extension, so PartExt never matches them.
+func ApplyToPart(name string, isDir bool, part NamePart, fn func(string) (string, bool)) (string, bool) {
+	stem, ext := splitName(name, isDir)
+
+	switch part {
+	case PartStem:
+		newStem, ok := fn(stem)
+		return newStem + ext, ok
+
+	case PartExt:
+		if ext == "" {
+			return name, false
+		}
+		newExt, ok := fn(strings.TrimPrefix(ext, "."))
+		if newExt == "" {
+			return stem, ok
+		}
+		return stem + "." + newExt, ok
+	}
+
+	return fn(name)
+}
+
+// PlanNameChanges builds a plan that renames each entry in place to the
+// name fn returns for the selected part. Entries fn declines are left alone.
+func PlanNameChanges(root string, entries []Entry, part NamePart, fn func(string) (string, bool)) (*Plan, error) {
+	plan := NewPlan(root)
+
+	for _, entry := range entries {
+		name := entry.Info.Name()
+		newName, ok := ApplyToPart(name, entry.Info.IsDir(), part, fn)
+		if !ok || newName == name {
+			continue
+		}
+		if err := validName(newName); err != nil {
+			return nil, fmt.Errorf("renaming %s: %v", entry.RelPath, err)
+		}
+
+		_, err := plan.Add(entry.Path, filepath.Join(filepath.Dir(entry.Path), newName), entry.Info.IsDir())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,183 @@
+package rename
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultSidecarExts are the extensions of files that usually accompany a
+// media file under the same stem: subtitles, metadata, artwork and lyrics
+var DefaultSidecarExts = []string{"srt", "ass", "ssa", "sub", "idx", "vtt", "nfo", "jpg", "jpeg", "png", "xmp", "lrc"}
+
+// ParseSidecarExts splits a comma separated extension list such as
+// "srt,.nfo, jpg" into lower-case extensions without dots
+func ParseSidecarExts(s string) ([]string, error) {
+	var exts []string
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext == "" {
+			continue
+		}
+		if strings.ContainsAny(ext, `./\`) {
+			return nil, fmt.Errorf("invalid sidecar extension '%s'", ext)
+		}
+		exts = append(exts, ext)
+	}
+	if len(exts) == 0 {
+		return nil, fmt.Errorf("empty sidecar extension list")
+	}
+	return exts, nil
+}
+
+// AddSidecars queues renames for the sidecar files of every pending file
+// rename, so that "movie.srt", "movie.en.srt" and "movie-poster.jpg" follow
+// "movie.mkv" to its new stem and directory. A sidecar is a sibling whose
+// name is the primary's stem followed by "." or "-" and ends in one of exts.
+// When several files could own a sidecar, the one with the longest stem
+// does. Sidecars that are already part of the plan are left as planned,
+// and deletions do not take their sidecars with them.
+func (p *Plan) AddSidecars(exts []string) error {
+	sidecarExt := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		sidecarExt[strings.ToLower(ext)] = true
+	}
+
+	primaries := make(map[string]*Rename)
+	var order []*Rename
+	for _, r := range p.Pending() {
+		if r.IsDir || r.NewPath == "" {
+			continue
+		}
+		primaries[r.OldPath] = r
+		order = append(order, r)
+	}
+
+	listings := make(map[string][]os.DirEntry)
+	for _, r := range order {
+		oldDir, newDir := filepath.Dir(r.OldPath), filepath.Dir(r.NewPath)
+		oldStem, _ := splitName(filepath.Base(r.OldPath), false)
+		newStem, _ := splitName(filepath.Base(r.NewPath), false)
+		if oldStem == newStem && oldDir == newDir {
+			continue
+		}
+
+		listing, seen := listings[oldDir]
+		if !seen {
+			var err error
+			listing, err = os.ReadDir(oldDir)
+			if err != nil {
+				return fmt.Errorf("failed to list %s: %v", p.rel(oldDir), err)
+			}
+			listings[oldDir] = listing
+		}
+
+		for _, entry := range listing {
+			name := entry.Name()
+			path := filepath.Join(oldDir, name)
+			if entry.IsDir() || path == r.OldPath || p.bySource[path] != nil {
+				continue
+			}
+			rest, ok := sidecarSuffix(name, oldStem, sidecarExt)
+			if !ok || sidecarOwner(oldDir, name, listing, primaries, sidecarExt) != r.OldPath {
+				continue
+			}
+
+			sidecar, err := p.Add(path, filepath.Join(newDir, newStem+rest), false)
+			if err != nil {
+				return err
+			}
+			if sidecar != nil {
+				sidecar.Label = r.Label
+				sidecar.SidecarOf = r
+				sidecar.Note = fmt.Sprintf("sidecar of %s", p.rel(r.OldPath))
+			}
+		}
+	}
+
+	return nil
+}
+
+// withoutSidecars drops the entries that AddSidecars would move along with
+// another selected file, so that commands numbering their entries, such as
+// rename-template, hand out numbers to primaries only
+func withoutSidecars(entries []Entry, exts []string) ([]Entry, error) {
+	sidecarExt := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		sidecarExt[strings.ToLower(ext)] = true
+	}
+
+	primaries := make(map[string]*Rename)
+	for _, entry := range entries {
+		_, ext := splitName(entry.Info.Name(), false)
+		if !entry.Info.IsDir() && !sidecarExt[strings.ToLower(strings.TrimPrefix(ext, "."))] {
+			primaries[entry.Path] = nil
+		}
+	}
+
+	listings := make(map[string][]os.DirEntry)
+	kept := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if _, isPrimary := primaries[entry.Path]; isPrimary || entry.Info.IsDir() {
+			kept = append(kept, entry)
+			continue
+		}
+
+		dir := filepath.Dir(entry.Path)
+		listing, seen := listings[dir]
+		if !seen {
+			var err error
+			listing, err = os.ReadDir(dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %v", dir, err)
+			}
+			listings[dir] = listing
+		}
+		if _, ownedBySelected := primaries[sidecarOwner(dir, entry.Info.Name(), listing, primaries, sidecarExt)]; !ownedBySelected {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
+
+// sidecarSuffix returns what follows stem in name when name is a sidecar of
+// a file with that stem, e.g. ".en.srt" or "-poster.jpg"
+func sidecarSuffix(name, stem string, sidecarExt map[string]bool) (string, bool) {
+	if !strings.HasPrefix(name, stem) {
+		return "", false
+	}
+	rest := name[len(stem):]
+	if !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "-") {
+		return "", false
+	}
+
+	dot := strings.LastIndexByte(rest, '.')
+	if dot < 0 || !sidecarExt[strings.ToLower(rest[dot+1:])] {
+		return "", false
+	}
+	return rest, true
+}
+
+// sidecarOwner returns the path of the file that owns the sidecar name: the
+// primary or non-sidecar sibling with the longest matching stem, so that
+// "movie.2.srt" belongs to "movie.2.mkv" rather than "movie.mkv"
+func sidecarOwner(dir, name string, listing []os.DirEntry, primaries map[string]*Rename, sidecarExt map[string]bool) string {
+	owner, ownerStem := "", -1
+	for _, entry := range listing {
+		candidate := entry.Name()
+		path := filepath.Join(dir, candidate)
+		if entry.IsDir() || candidate == name {
+			continue
+		}
+
+		stem, ext := splitName(candidate, false)
+		if _, isPrimary := primaries[path]; !isPrimary && sidecarExt[strings.ToLower(strings.TrimPrefix(ext, "."))] {
+			continue
+		}
+		if _, ok := sidecarSuffix(name, stem, sidecarExt); ok && len(stem) > ownerStem {
+			owner, ownerStem = path, len(stem)
+		}
+	}
+	return owner
+}
@@ -0,0 +1,516 @@
+package rename
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"filekit/internal/metadata"
+)
+
+// SortOrder decides the order in which entries are numbered
+type SortOrder string
+
+const (
+	SortName    SortOrder = "name"
+	SortNatural SortOrder = "natural"
+	SortMtime   SortOrder = "mtime"
+	SortSize    SortOrder = "size"
+)
+
+// ParseSortOrder converts a flag value into a SortOrder
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch o := SortOrder(strings.ToLower(s)); o {
+	case SortName, SortNatural, SortMtime, SortSize:
+		return o, nil
+	}
+	return "", fmt.Errorf("invalid sort order '%s' (expected name, natural, mtime or size)", s)
+}
+
+// TemplateOptions controls RenameTemplate
+type TemplateOptions struct {
+	// Sort sets the numbering order; the zero value sorts by name
+	Sort SortOrder
+	// Reverse numbers in descending order
+	Reverse bool
+	// Start is the first number handed out
+	Start int
+	// PerDir restarts numbering in every directory
+	PerDir bool
+	// Scope selects which files and directories are visited
+	Scope
+	// ExecOptions controls how the planned renames are carried out
+	ExecOptions
+}
+
+// RenameTemplate renames every selected entry under dir to the name produced
+// by template. See ParseTemplate for the available tokens.
+func RenameTemplate(dir, template string, opts TemplateOptions) (int, error) {
+	tmpl, err := ParseTemplate(template)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := Collect(dir, opts.Scope)
+	if err != nil {
+		return 0, err
+	}
+
+	plan, err := PlanTemplate(dir, entries, tmpl, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return Execute(plan, opts.ExecOptions)
+}
+
+// PlanTemplate numbers entries in the configured order and plans renaming
+// each of them to the name tmpl produces. With sidecars, sidecar files are
+// not numbered themselves and follow their primary instead.
+func PlanTemplate(root string, entries []Entry, tmpl *Template, opts TemplateOptions) (*Plan, error) {
+	if len(opts.Sidecars) > 0 {
+		var err error
+		if entries, err = withoutSidecars(entries, opts.Sidecars); err != nil {
+			return nil, err
+		}
+	}
+
+	groups := [][]Entry{entries}
+	if opts.PerDir {
+		groups = groupByDir(entries)
+	}
+
+	plan := NewPlan(root)
+	for _, group := range groups {
+		SortEntries(group, opts.Sort, opts.Reverse)
+
+		for i, entry := range group {
+			name, err := tmpl.Execute(entry, opts.Start+i)
+			var missing *missingMetadata
+			if errors.As(err, &missing) {
+				// Mixed folders are normal; leave files without the tag alone
+				plan.Skip(entry.Path, "", entry.Info.IsDir(), err.Error())
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("template for %s: %v", entry.RelPath, err)
+			}
+			if err := validName(name); err != nil {
+				return nil, fmt.Errorf("template for %s: %v", entry.RelPath, err)
+			}
+
+			_, err = plan.Add(entry.Path, filepath.Join(filepath.Dir(entry.Path), name), entry.Info.IsDir())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// groupByDir splits entries by parent directory, keeping first-seen order
+func groupByDir(entries []Entry) [][]Entry {
+	var groups [][]Entry
+	index := make(map[string]int)
+	for _, entry := range entries {
+		parent := filepath.Dir(entry.Path)
+		i, exists := index[parent]
+		if !exists {
+			i = len(groups)
+			index[parent] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], entry)
+	}
+	return groups
+}
+
+// SortEntries orders entries in place; ties are broken by path
+func SortEntries(entries []Entry, order SortOrder, reverse bool) {
+	less := func(a, b Entry) bool {
+		switch order {
+		case SortNatural:
+			if a.Info.Name() != b.Info.Name() {
+				return naturalLess(a.Info.Name(), b.Info.Name())
+			}
+		case SortMtime:
+			if !a.Info.ModTime().Equal(b.Info.ModTime()) {
+				return a.Info.ModTime().Before(b.Info.ModTime())
+			}
+		case SortSize:
+			if a.Info.Size() != b.Info.Size() {
+				return a.Info.Size() < b.Info.Size()
+			}
+		}
+		return a.Path < b.Path
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if reverse {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+// naturalLess compares names so that embedded numbers sort by value, putting
+// "ep2" before "ep10". Letters compare case-insensitively.
+func naturalLess(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si := i
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			sj := j
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	if len(ra)-i != len(rb)-j {
+		return len(ra)-i < len(rb)-j
+	}
+	return a < b
+}
+
+// Template is a parsed name template
+type Template struct {
+	parts []templatePart
+}
+
+// templatePart is either literal text or a {token:arg} placeholder
+type templatePart struct {
+	literal string
+	token   string
+	arg     string
+}
+
+// templateToken produces the text for one placeholder
+type templateToken func(ctx *templateContext, arg string) (string, error)
+
+// templateContext holds what a template is evaluated against
+type templateContext struct {
+	entry Entry
+	n     int
+
+	// Embedded metadata, read on first use
+	meta       *metadata.Metadata
+	metaErr    error
+	metaLoaded bool
+}
+
+// missingMetadata marks an entry that lacks a tag the template needs
+type missingMetadata struct {
+	reason string
+}
+
+func (e *missingMetadata) Error() string {
+	return e.reason
+}
+
+var templateTokens = map[string]templateToken{
+	"n":    tokenNumber,
+	"name": func(ctx *templateContext, _ string) (string, error) { return ctx.entry.Info.Name(), nil },
+	"stem": func(ctx *templateContext, _ string) (string, error) { return ctx.stem(), nil },
+	"ext":  func(ctx *templateContext, _ string) (string, error) { return ctx.ext(), nil },
+	"parent": func(ctx *templateContext, _ string) (string, error) {
+		return filepath.Base(filepath.Dir(ctx.entry.Path)), nil
+	},
+	"mtime": tokenMtime,
+	"size": func(ctx *templateContext, _ string) (string, error) {
+		return strconv.FormatInt(ctx.entry.Info.Size(), 10), nil
+	},
+	"sha256": tokenSHA256,
+
+	"date":   tokenDate,
+	"make":   metadataToken("camera make", func(m *metadata.Metadata) string { return m.Make }),
+	"model":  metadataToken("camera model", func(m *metadata.Metadata) string { return m.Model }),
+	"seq":    tokenSeq,
+	"artist": metadataToken("artist", func(m *metadata.Metadata) string { return firstNonEmpty(m.Artist, m.AlbumArtist) }),
+	"album":  metadataToken("album", func(m *metadata.Metadata) string { return m.Album }),
+	"title":  metadataToken("title", func(m *metadata.Metadata) string { return m.Title }),
+	"track":  tokenTrack,
+	"year":   metadataToken("year", func(m *metadata.Metadata) string { return m.Year }),
+}
+
+// ParseTemplate parses a name template. Placeholders are written as {token}
+// or {token:arg}, and "{{" / "}}" produce literal braces:
+//
+//	{n}, {n:03}          sequence number, optionally zero-padded to a width
+//	{name}, {stem}       original name, and without its extension
+//	{ext}                original extension including the dot
+//	{parent}             name of the containing directory
+//	{mtime}, {mtime:L}   modification time, formatted with Go layout L
+//	{size}               size in bytes
+//	{sha256}, {sha256:8} hex SHA-256 of the content, optionally shortened
+//
+// Metadata tokens read EXIF from JPEG/TIFF and ID3 or Vorbis comments from
+// MP3, FLAC and Ogg files. Entries missing a tag are skipped:
+//
+//	{date}, {date:L}     EXIF DateTimeOriginal, formatted with Go layout L
+//	{make}, {model}      camera make and model
+//	{seq}, {seq:04}      camera image number, optionally zero-padded
+//	{artist}, {album}    artist (or album artist) and album
+//	{title}, {year}      track title and release year
+//	{track}, {track:02}  track number, optionally zero-padded
+func ParseTemplate(s string) (*Template, error) {
+	tmpl := &Template{}
+	var literal strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '{' && i+1 < len(s) && s[i+1] == '{':
+			literal.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(s) && s[i+1] == '}':
+			literal.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '{' in template '%s'", s)
+			}
+			token, arg, _ := strings.Cut(s[i+1:i+end], ":")
+			if _, exists := templateTokens[token]; !exists {
+				return nil, fmt.Errorf("unknown template token '{%s}'", token)
+			}
+			if literal.Len() > 0 {
+				tmpl.parts = append(tmpl.parts, templatePart{literal: literal.String()})
+				literal.Reset()
+			}
+			tmpl.parts = append(tmpl.parts, templatePart{token: token, arg: arg})
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("unexpected '}' in template '%s'", s)
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	if literal.Len() > 0 {
+		tmpl.parts = append(tmpl.parts, templatePart{literal: literal.String()})
+	}
+
+	return tmpl, nil
+}
+
+// Execute produces the name for entry, which is number n in the sequence
+func (t *Template) Execute(entry Entry, n int) (string, error) {
+	ctx := &templateContext{entry: entry, n: n}
+
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.token == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		value, err := templateTokens[part.token](ctx, part.arg)
+		if err != nil {
+			return "", fmt.Errorf("{%s}: %w", part.token, err)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+func (ctx *templateContext) stem() string {
+	stem, _ := splitName(ctx.entry.Info.Name(), ctx.entry.Info.IsDir())
+	return stem
+}
+
+func (ctx *templateContext) ext() string {
+	_, ext := splitName(ctx.entry.Info.Name(), ctx.entry.Info.IsDir())
+	return ext
+}
+
+func tokenNumber(ctx *templateContext, arg string) (string, error) {
+	if arg == "" {
+		return strconv.Itoa(ctx.n), nil
+	}
+	width, err := strconv.Atoi(arg)
+	if err != nil || width < 0 {
+		return "", fmt.Errorf("invalid width '%s'", arg)
+	}
+	return fmt.Sprintf("%0*d", width, ctx.n), nil
+}
+
+func tokenMtime(ctx *templateContext, arg string) (string, error) {
+	layout := arg
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	return ctx.entry.Info.ModTime().Format(layout), nil
+}
+
+func tokenSHA256(ctx *templateContext, arg string) (string, error) {
+	if ctx.entry.Info.IsDir() {
+		return "", fmt.Errorf("cannot hash a directory")
+	}
+
+	length := sha256.Size * 2
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > length {
+			return "", fmt.Errorf("invalid length '%s'", arg)
+		}
+		length = n
+	}
+
+	file, err := os.Open(ctx.entry.Path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:length], nil
+}
+
+// loadMetadata returns the entry's embedded metadata, reading it once
+func (ctx *templateContext) loadMetadata() (*metadata.Metadata, error) {
+	if !ctx.metaLoaded {
+		ctx.metaLoaded = true
+		if ctx.entry.Info.IsDir() {
+			ctx.metaErr = &missingMetadata{"directories have no metadata"}
+		} else {
+			// A damaged tag should not stop the rest of the batch
+			meta, err := metadata.Read(ctx.entry.Path)
+			switch {
+			case errors.Is(err, metadata.ErrUnsupported):
+				ctx.metaErr = &missingMetadata{"no supported metadata"}
+			case err != nil:
+				ctx.metaErr = &missingMetadata{err.Error()}
+			default:
+				ctx.meta = meta
+			}
+		}
+	}
+	return ctx.meta, ctx.metaErr
+}
+
+// metadataToken builds a token for a text field; what names it in the error
+// for files that lack it
+func metadataToken(what string, field func(*metadata.Metadata) string) templateToken {
+	return func(ctx *templateContext, _ string) (string, error) {
+		meta, err := ctx.loadMetadata()
+		if err != nil {
+			return "", err
+		}
+		value := cleanTagValue(field(meta))
+		if value == "" {
+			return "", &missingMetadata{"no " + what}
+		}
+		return value, nil
+	}
+}
+
+func tokenDate(ctx *templateContext, arg string) (string, error) {
+	meta, err := ctx.loadMetadata()
+	if err != nil {
+		return "", err
+	}
+	if meta.DateTimeOriginal.IsZero() {
+		return "", &missingMetadata{"no EXIF date"}
+	}
+
+	layout := arg
+	if layout == "" {
+		layout = "2006-01-02_150405"
+	}
+	return meta.DateTimeOriginal.Format(layout), nil
+}
+
+func tokenSeq(ctx *templateContext, arg string) (string, error) {
+	meta, err := ctx.loadMetadata()
+	if err != nil {
+		return "", err
+	}
+	if meta.ImageNumber == "" {
+		return "", &missingMetadata{"no image number"}
+	}
+	return padNumber(meta.ImageNumber, arg)
+}
+
+func tokenTrack(ctx *templateContext, arg string) (string, error) {
+	meta, err := ctx.loadMetadata()
+	if err != nil {
+		return "", err
+	}
+	if meta.Track == "" {
+		return "", &missingMetadata{"no track number"}
+	}
+	return padNumber(meta.Track, arg)
+}
+
+// padNumber zero-pads a numeric tag value to the width in arg
+func padNumber(value, arg string) (string, error) {
+	if arg == "" {
+		return value, nil
+	}
+	width, err := strconv.Atoi(arg)
+	if err != nil || width < 0 {
+		return "", fmt.Errorf("invalid width '%s'", arg)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return cleanTagValue(value), nil
+	}
+	return fmt.Sprintf("%0*d", width, n), nil
+}
+
+// cleanTagValue makes a tag usable inside a file name: path separators
+// become dashes and control characters are dropped, so "AC/DC" stays one
+// name instead of turning into a directory
+func cleanTagValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '-'
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, value)
+	return strings.TrimSpace(value)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []templatePart
+		wantErr string
+	}{
+		{"photo", []templatePart{{literal: "photo"}}, ""},
+		{"Trip_{n:03}{ext}", []templatePart{{literal: "Trip_"}, {token: "n", arg: "03"}, {token: "ext"}}, ""},
+		{"{mtime:2006-01-02 15:04}_{name}", []templatePart{{token: "mtime", arg: "2006-01-02 15:04"}, {literal: "_"}, {token: "name"}}, ""},
+		{"{{n}} {n}", []templatePart{{literal: "{n} "}, {token: "n"}}, ""},
+		{"a}}b{{", []templatePart{{literal: "a}b{"}}, ""},
+		{"", nil, ""},
+		{"{n", nil, "unclosed '{'"},
+		{"n}", nil, "unexpected '}'"},
+		{"{count}", nil, "unknown template token '{count}'"},
+		{"{}", nil, "unknown template token '{}'"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTemplate(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseTemplate(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got.parts, tt.want) {
+			t.Errorf("ParseTemplate(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestTemplateExecute(t *testing.T) {
+	root := makeTree(t, map[string]string{"album/Song.tar.gz": "abc"})
+	path := filepath.Join(root, "album", "Song.tar.gz")
+	mtime := time.Date(2024, 5, 3, 14, 30, 0, 0, time.Local)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := Entry{Path: path, RelPath: filepath.Join("album", "Song.tar.gz"), Info: info}
+
+	tests := []struct {
+		template string
+		want     string
+		wantErr  string
+	}{
+		{"{n}-{n:03}", "7-007", ""},
+		{"{parent} - {stem}{ext}", "album - Song.tar.gz", ""},
+		{"{name}", "Song.tar.gz", ""},
+		{"{mtime}_{mtime:1504}", "2024-05-03_1430", ""},
+		{"{size}", "3", ""},
+		{"{sha256:8}", "ba7816bf", ""},
+		{"{n:x}", "", "invalid width 'x'"},
+		{"{title}", "", "{title}"},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := tmpl.Execute(entry, 7)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Execute(%q) = %q, %v; want error %q", tt.template, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Execute(%q) = %q, %v; want %q", tt.template, got, err, tt.want)
+		}
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"ep2", "ep10", true},
+		{"ep10", "ep2", false},
+		// Equal values fall back to comparing the names as they are
+		{"ep02", "ep2", true},
+		{"ep2", "ep02", false},
+		{"ep002", "ep10", true},
+		{"File", "file2", true},
+		{"a", "B", true},
+		{"B", "a", false},
+		{"img9.jpg", "img10.jpg", true},
+		{"img10.jpg", "img10.png", true},
+		{"v1.2.10", "v1.2.9", false},
+		{"track", "track1", true},
+		{"2024-01 b", "2024-1 a", false},
+		{"same", "same", false},
+	}
+
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRenameTemplateSidecars(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"a.mkv":        "A",
+		"movie.mkv":    "M",
+		"movie.en.srt": "S",
+		"movie.nfo":    "N",
+		"poster.jpg":   "P",
+		"z.mkv":        "Z",
+	})
+
+	opts := TemplateOptions{Start: 1, ExecOptions: ExecOptions{Sidecars: DefaultSidecarExts}}
+	if _, err := RenameTemplate(root, "Film_{n:02}{ext}", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lone poster has no primary and is numbered like any other file
+	want := map[string]string{
+		"Film_01.mkv":    "A",
+		"Film_02.mkv":    "M",
+		"Film_02.en.srt": "S",
+		"Film_02.nfo":    "N",
+		"Film_03.jpg":    "P",
+		"Film_04.mkv":    "Z",
+	}
+	if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+}
@@ -19,6 +19,26 @@ func main() {
 	switch command {
 	case "rename-replace":
 		cmd.ExecuteReplaceInNames(args)
+	case "rename-path":
+		cmd.ExecuteRenamePath(args)
+	case "rename-case":
+		cmd.ExecuteRenameCase(args)
+	case "rename-template":
+		cmd.ExecuteRenameTemplate(args)
+	case "rename-dates":
+		cmd.ExecuteRenameDates(args)
+	case "rename-pipeline":
+		cmd.ExecuteRenamePipeline(args)
+	case "rename-pad":
+		cmd.ExecuteRenamePad(args)
+	case "rename-map":
+		cmd.ExecuteRenameMap(args)
+	case "rename-edit":
+		cmd.ExecuteRenameEdit(args)
+	case "sanitize-names":
+		cmd.ExecuteSanitizeNames(args)
+	case "fix-encoding":
+		cmd.ExecuteFixEncoding(args)
 	case "create-rand-files":
 		cmd.ExecuteCreateRandFiles(args)
 	case "folderify":
@@ -40,11 +60,63 @@ func printUsage() {
 	fmt.Println("Usage: tools <cmd> <flags> [directory]")
 	fmt.Println("")
 	fmt.Println("Available commands:")
-	fmt.Println("  rename-replace -target=\"\" [-replaceWith=\"\"] [directory]")
+	fmt.Println("  rename-replace -target=\"\" [-replaceWith=\"\"] [-regex] [-ignore-case] [-part=full] [scope flags] [-dry-run] [-on-conflict=abort] [directory]")
 	fmt.Println("    Renames all files by replacing target string with replaceWith string (or removes target if replaceWith not specified)")
+	fmt.Println("    Use -regex to treat target as a regular expression with $1/${name} expansions in replaceWith")
+	fmt.Println("    Use -dirs to also rename directories, or -dirs-only to rename only directories")
+	fmt.Println("    Scope flags: -max-depth=num, -include=glob, -exclude=glob (repeatable), -skip-hidden; -part=stem|ext|full")
+	fmt.Println("    Use -dry-run to preview; -on-conflict=skip|abort|suffix|overwrite handles name collisions")
+	fmt.Println("    -sidecars renames movie.srt, movie.en.srt, movie-poster.jpg ... together with movie.mkv (all rename commands)")
+	fmt.Println("    -update-refs rewrites references in .m3u/.m3u8, .cue, .md and .html files to renamed files (all rename commands)")
 	fmt.Println("")
-	fmt.Println("  create-rand-files -depth=num -count=num [directory]")
+	fmt.Println("  rename-path -target=\"old\" [-replaceWith=\"new\"] [-regex] [-ignore-case] [-remove-empty-dirs] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Rewrites the path relative to the directory, moving files into new directories as needed")
+	fmt.Println("    Example: -regex -target='^Season (\\d+)/Show - (\\d+)' -replaceWith='Show/S0${1}/E${2}'")
+	fmt.Println("")
+	fmt.Println("  rename-case -mode=lower|upper|title|snake|kebab|camel|nfc|nfd [-part=stem] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Converts the case of names or normalizes their Unicode form (NFC/NFD)")
+	fmt.Println("    Case-only renames go through a temporary name so they work on case-insensitive filesystems")
+	fmt.Println("")
+	fmt.Println("  rename-template -template=\"Trip_{n:03}{ext}\" [-sort=name|natural|mtime|size] [-start=1] [-per-dir] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Renames files from a template with tokens such as {n:03}, {stem}, {ext}, {parent}, {mtime:2006-01-02}, {size}, {sha256:8}")
+	fmt.Println("    Metadata tokens: {date}, {make}, {model}, {seq} from EXIF; {artist}, {album}, {title}, {track:02}, {year} from ID3/Vorbis tags")
+	fmt.Println("")
+	fmt.Println("  rename-dates [-order=dmy|mdy|ymd] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Rewrites dates in names (12-31-2023, 31.12.23, Dec 31 2023, 20231231) as ISO 8601 (2023-12-31)")
+	fmt.Println("    Names without a date, or with invalid or ambiguous dates, are reported and left unchanged")
+	fmt.Println("")
+	fmt.Println("  rename-pipeline -op=step [-op=step]... | -recipe=file.yaml [-part=stem] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Runs each name through ordered steps: prefix:T, suffix:T, insert:POS:T, remove:POS:N, trim, collapse,")
+	fmt.Println("    replace:OLD:NEW, regex:PATTERN:REPL, case:MODE; the first character after the name is the delimiter")
+	fmt.Println("")
+	fmt.Println("  rename-pad [-width=0] [-numbers=all|first|last] [-strip] [-part=stem] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Zero-pads numbers in names to the widest in each directory (ep1..ep10 -> ep01..ep10) or a fixed width;")
+	fmt.Println("    -strip removes the padding again")
+	fmt.Println("")
+	fmt.Println("  rename-map -map=file.csv|tsv|json [-format=csv] [-dry-run] [-on-conflict=abort] [directory]")
+	fmt.Println("    Renames files from a mapping file of old and new names and reports every row")
+	fmt.Println("")
+	fmt.Println("  rename-edit [-editor=cmd] [-delete] [-yes] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Opens the file list in $EDITOR; edited lines become renames and moves, removed lines deletions (with -delete)")
+	fmt.Println("")
+	fmt.Println("  sanitize-names [-replacement=_] [-ascii] [-max-bytes=255] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Fixes names that break on Windows and other platforms: illegal and control characters, reserved names,")
+	fmt.Println("    trailing dots and spaces, and names over the byte limit; -ascii also transliterates to ASCII")
+	fmt.Println("")
+	fmt.Println("  fix-encoding [-from=cp1252,shift-jis] [scope flags] [-dry-run] [directory]")
+	fmt.Println("    Renames names that are not valid UTF-8 by decoding them from a legacy charset (latin1, cp1252, shift-jis, ...)")
+	fmt.Println("    With several charsets the most plausible one is picked per name; use -dry-run to preview")
+	fmt.Println("")
+	fmt.Println("  create-rand-files -depth=num -count=num [-seed=num] [-manifest=out.json] [directory]")
 	fmt.Println("    Creates random txt files with random names in the specified directory")
+	fmt.Println("    Tree shape: -fanout=num, -min-depth=num, -max-depth=num, -dir-names=level|random|a,b,c, -spread (files on every level)")
+	fmt.Println("    Sizes: -size=4K, -min-size, -max-size, -size-dist=fixed|uniform|lognormal, -total-size=1G; -content=text|random|compressible|sparse")
+	fmt.Println("    Types: -types=png:30,txt:50,zip:20 (txt, png, jpeg, gif, zip, tar.gz, json, csv, pdf, mp3)")
+	fmt.Println("    Names: -names=edge for Unicode (NFC and NFD), emoji, spaces, leading dots and dashes, 255-byte, case-only and glob names, and a very deep branch")
+	fmt.Println("    Duplicates: -dup-ratio=0.2, -hardlink-ratio, -same-size-ratio, -last-byte-ratio; -groups=groups.json writes the expected dedup groups")
+	fmt.Println("    Use -jobs=8 to create files in parallel; taken names get a number, so existing files are never replaced")
+	fmt.Println("    Use -seed to build the same tree every time and -manifest to list each file with its size and SHA-256")
+	fmt.Println("    Use -spec=tree.yaml to build an exact tree of files, directories, links, modes and mtimes from a JSON or YAML spec")
 	fmt.Println("")
 	fmt.Println("  folderify [-recursive] [directory]")
 	fmt.Println("    Creates folders with file names (minus extension) and moves files into them")